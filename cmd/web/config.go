@@ -4,8 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -18,6 +21,13 @@ type Config struct {
 	Port int
 	Env  string
 
+	// The URL the application is reached at by its users, such as
+	// https://contacts.example.com, without a trailing slash. It is used to
+	// build links that are used outside of the app, like share links, rather
+	// than trusting the request's Host header. Defaults to
+	// http://localhost:<Port>.
+	BaseURL string
+
 	// Sends full stack trace of server errors in response.
 	Debug BoolFlag
 
@@ -25,6 +35,74 @@ type Config struct {
 	// middleware.logRequest makes use of this.
 	Verbose BoolFlag
 	DB      DatabaseConfig
+
+	// The port to serve /metrics on. If zero, /metrics is served on Port along
+	// with the rest of the application.
+	MetricsPort int
+
+	// How long to keep serving requests after receiving a shutdown signal, with
+	// GET /readyz failing, before shutting down the server.
+	DrainTime time.Duration
+
+	Limiter   LimiterConfig
+	SMTP      SMTPConfig
+	Webhooks  WebhooksConfig
+	Reminders RemindersConfig
+}
+
+// RemindersConfig is a struct that stores the configuration of the reminder
+// scheduler. See reminders.Scheduler.
+type RemindersConfig struct {
+	// How often to check for due reminders.
+	PollInterval time.Duration
+
+	// The address to email fired reminders to. If empty, reminders are only
+	// shown as in-app notifications.
+	Email string
+}
+
+// WebhooksConfig is a struct that stores the configuration of the webhook
+// delivery worker. See webhooks.Worker.
+type WebhooksConfig struct {
+	// How often to check for new events and due deliveries.
+	PollInterval time.Duration
+
+	// The maximum time a single delivery request may take.
+	Timeout time.Duration
+
+	// How long to keep processed events and finished deliveries. If zero, they
+	// are kept forever.
+	Retention time.Duration
+}
+
+// SMTPConfig is a struct that stores the configuration of the SMTP server used
+// to send emails. If Host is empty, emails are logged instead of sent, and
+// written to MailDir if it is set.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+	MailDir  string
+
+	// The maximum time each attempt to send an email may take.
+	Timeout time.Duration
+}
+
+// LimiterConfig is a struct that stores the configuration of the per-IP rate
+// limiter. See the rateLimit middleware.
+type LimiterConfig struct {
+	Enabled BoolFlag
+
+	// Average requests per second allowed for each IP.
+	RPS float64
+
+	// Maximum number of requests allowed in a single burst.
+	Burst int
+
+	// Networks of proxies that are trusted to set X-Forwarded-For.
+	TrustedProxies []*net.IPNet
 }
 
 // DatabaseConfig is a struct that stores database configuration. The DSN field
@@ -35,6 +113,9 @@ type DatabaseConfig struct {
 	MaxOpenConns int
 	MaxIdleConns int
 	MaxIdleTime  time.Duration
+
+	// The maximum time a single query may run before it is cancelled.
+	QueryTimeout time.Duration
 }
 
 // BoolFlag is a struct to store boolean flags. It implements the Set method
@@ -67,6 +148,20 @@ func (b *BoolFlag) String() string {
 	return fmt.Sprintf("%v", b.value)
 }
 
+// loadStringFromEnvOrFlag loads a string valued config option and assigns it
+// to the target. This function should be called after flags are parsed with
+// flag.Parse.
+//
+// If the target still has the default value, the function checks for a
+// matching environmental variable, and assigns it to the target if it exists.
+func loadStringFromEnvOrFlag(target *string, defaultVal string, envKey string) {
+	if *target == defaultVal {
+		if envVar, ok := os.LookupEnv(envKey); ok {
+			*target = envVar
+		}
+	}
+}
+
 // loadIntFromEnvOrFlag loads an integer valued config option and assigns it to
 // the target int. This function should be called after flags are parsed with
 // flag.Parse.
@@ -107,6 +202,74 @@ func loadDurationFromEnvOrFlag(target *time.Duration, defaultVal time.Duration,
 	}
 }
 
+// loadFloatFromEnvOrFlag loads a float64 valued config option and assigns it
+// to the target. This function should be called after flags are parsed with
+// flag.Parse.
+//
+// It then checks if the target has the default value. If not, no action is
+// taken, because the flags should override environmental variables. If it still
+// has the default value, the function checks for a matching environmental
+// variable. If it exists and can be converted into a float64, it is assigned
+// to the target.
+func loadFloatFromEnvOrFlag(target *float64, defaultVal float64, envKey string) {
+	if *target == defaultVal {
+		if envVar, ok := os.LookupEnv(envKey); ok {
+			val, err := strconv.ParseFloat(envVar, 64)
+			if err == nil {
+				*target = val
+			}
+		}
+	}
+}
+
+// parseTrustedProxies parses a comma separated list of IP addresses and CIDR
+// networks, such as "10.0.0.0/8,192.168.1.1". Single IP addresses are treated
+// as networks containing only that address.
+func parseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// parseBaseURL validates a base URL, which must be an absolute http or https
+// URL with no query or fragment, and returns it without a trailing slash.
+func parseBaseURL(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid base URL %q: must be an absolute http or https URL", s)
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("invalid base URL %q: must not have credentials, a query or a fragment", s)
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
 // LoadConfig loads the configuration, returning the resulting Config struct.
 // It first loads environmental variables from the environment, including from
 // a .env file. Then, if any command line flags have been set, these will
@@ -128,14 +291,48 @@ func LoadConfig() Config {
 		"env",
 		"development",
 		"Environment (development|staging|production)")
+	flag.StringVar(&cfg.BaseURL, "base-url", "", "URL users reach the app at, for links used outside the app (default http://localhost:<port>)")
 	flag.Var(&cfg.Debug, "debug", "Run in debug mode")
 	flag.Var(&cfg.Verbose, "verbose", "Provide verbose logging")
+	flag.IntVar(&cfg.MetricsPort, "metrics-port", 0, "Separate admin port to serve /metrics on (0 to serve on -port)")
+	flag.DurationVar(&cfg.DrainTime, "drain-time", 5*time.Second, "Time to fail readiness checks before shutting down")
+
+	// Read rate limiter settings from CLI flags.
+	flag.Var(&cfg.Limiter.Enabled, "limiter-enabled", "Enable per-IP rate limiting (default true)")
+	flag.Float64Var(&cfg.Limiter.RPS, "limiter-rps", 10, "Rate limiter average requests per second, per IP")
+	flag.IntVar(&cfg.Limiter.Burst, "limiter-burst", 40, "Rate limiter maximum burst, per IP")
+	var trustedProxiesSet bool
+	flag.Func("trusted-proxies", "Comma separated IPs or CIDRs of proxies trusted to set X-Forwarded-For", func(s string) error {
+		var err error
+		cfg.Limiter.TrustedProxies, err = parseTrustedProxies(s)
+		trustedProxiesSet = true
+		return err
+	})
+
+	// Read SMTP settings from CLI flags.
+	flag.StringVar(&cfg.SMTP.Host, "smtp-host", "", "SMTP host (if empty, emails are logged instead of sent)")
+	flag.IntVar(&cfg.SMTP.Port, "smtp-port", 587, "SMTP port")
+	flag.StringVar(&cfg.SMTP.Username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.SMTP.Password, "smtp-password", "", "SMTP password")
+	flag.StringVar(&cfg.SMTP.Sender, "smtp-sender", "Contact.app <no-reply@contacts.local>", "SMTP sender")
+	flag.StringVar(&cfg.SMTP.MailDir, "mail-dir", "", "Directory to write unsent emails to, when -smtp-host is empty")
+	flag.DurationVar(&cfg.SMTP.Timeout, "smtp-timeout", 10*time.Second, "Timeout of each attempt to send an email")
+
+	// Read webhook settings from CLI flags.
+	flag.DurationVar(&cfg.Webhooks.PollInterval, "webhook-poll-interval", 5*time.Second, "How often to check for webhooks to deliver")
+	flag.DurationVar(&cfg.Webhooks.Timeout, "webhook-timeout", 10*time.Second, "Timeout of each webhook delivery request")
+	flag.DurationVar(&cfg.Webhooks.Retention, "webhook-retention", 30*24*time.Hour, "How long to keep delivered webhook events (0 keeps them forever)")
+
+	// Read reminder settings from CLI flags.
+	flag.DurationVar(&cfg.Reminders.PollInterval, "reminder-poll-interval", 30*time.Second, "How often to check for due reminders")
+	flag.StringVar(&cfg.Reminders.Email, "reminder-email", "", "Address to email reminders to (if empty, reminders are only shown in the app)")
 
 	// Read DB-related settings from CLI flags.
 	flag.StringVar(&cfg.DB.DSN, "db-dsn", "", "Postgresql DSN")
 	flag.IntVar(&cfg.DB.MaxOpenConns, "db-max-open-conns", 25, "Postgresql max open connections")
 	flag.IntVar(&cfg.DB.MaxIdleConns, "db-max-idle-conns", 25, "Postgresql max idle connections")
 	flag.DurationVar(&cfg.DB.MaxIdleTime, "db-max-idle-time", 15*time.Minute, "Postgresql max connection idle time")
+	flag.DurationVar(&cfg.DB.QueryTimeout, "db-query-timeout", 3*time.Second, "Postgresql per-query timeout")
 
 	flag.Parse()
 
@@ -145,11 +342,51 @@ func LoadConfig() Config {
 		cfg.DB.DSN = os.Getenv("DB_DSN")
 	}
 
+	// Load string valued SMTP options from the environment, unless supplied by
+	// flag.
+	loadStringFromEnvOrFlag(&cfg.SMTP.Host, "", "SMTP_HOST")
+	loadStringFromEnvOrFlag(&cfg.SMTP.Username, "", "SMTP_USERNAME")
+	loadStringFromEnvOrFlag(&cfg.SMTP.Password, "", "SMTP_PASSWORD")
+	loadStringFromEnvOrFlag(&cfg.SMTP.Sender, "Contact.app <no-reply@contacts.local>", "SMTP_SENDER")
+	loadStringFromEnvOrFlag(&cfg.SMTP.MailDir, "", "MAIL_DIR")
+	loadStringFromEnvOrFlag(&cfg.Reminders.Email, "", "REMINDER_EMAIL")
+
 	// Load integer and duration valued configuration options.
 	loadIntFromEnvOrFlag(&cfg.Port, 4000, "PORT")
+	loadIntFromEnvOrFlag(&cfg.MetricsPort, 0, "METRICS_PORT")
 	loadIntFromEnvOrFlag(&cfg.DB.MaxOpenConns, 25, "DB_MAX_OPEN_CONNS")
 	loadIntFromEnvOrFlag(&cfg.DB.MaxIdleConns, 25, "DB_MAX_IDLE_CONNS")
 	loadDurationFromEnvOrFlag(&cfg.DB.MaxIdleTime, 15*time.Minute, "DB_MAX_IDLE_TIME")
+	loadDurationFromEnvOrFlag(&cfg.DB.QueryTimeout, 3*time.Second, "DB_QUERY_TIMEOUT")
+	loadDurationFromEnvOrFlag(&cfg.DrainTime, 5*time.Second, "DRAIN_TIME")
+	loadFloatFromEnvOrFlag(&cfg.Limiter.RPS, 10, "LIMITER_RPS")
+	loadIntFromEnvOrFlag(&cfg.Limiter.Burst, 40, "LIMITER_BURST")
+	loadIntFromEnvOrFlag(&cfg.SMTP.Port, 587, "SMTP_PORT")
+	loadDurationFromEnvOrFlag(&cfg.SMTP.Timeout, 10*time.Second, "SMTP_TIMEOUT")
+	loadDurationFromEnvOrFlag(&cfg.Webhooks.PollInterval, 5*time.Second, "WEBHOOK_POLL_INTERVAL")
+	loadDurationFromEnvOrFlag(&cfg.Webhooks.Timeout, 10*time.Second, "WEBHOOK_TIMEOUT")
+	loadDurationFromEnvOrFlag(&cfg.Webhooks.Retention, 30*24*time.Hour, "WEBHOOK_RETENTION")
+	loadDurationFromEnvOrFlag(&cfg.Reminders.PollInterval, 30*time.Second, "REMINDER_POLL_INTERVAL")
+
+	// Load the base URL, defaulting to the local port. An invalid value is
+	// fatal, since it would break every share and calendar link.
+	loadStringFromEnvOrFlag(&cfg.BaseURL, "", "BASE_URL")
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = fmt.Sprintf("http://localhost:%d", cfg.Port)
+	}
+	cfg.BaseURL, err = parseBaseURL(cfg.BaseURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load trusted proxies, if they weren't supplied by flag. Since they affect
+	// which client IPs are rate limited, an invalid value is fatal.
+	if !trustedProxiesSet {
+		cfg.Limiter.TrustedProxies, err = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// Load Boolean valued configuration options.
 	if !cfg.Verbose.isSet {
@@ -158,6 +395,9 @@ func LoadConfig() Config {
 	if !cfg.Debug.isSet {
 		cfg.Debug.value = os.Getenv("DEBUG") == "true"
 	}
+	if !cfg.Limiter.Enabled.isSet {
+		cfg.Limiter.Enabled.value = os.Getenv("LIMITER_ENABLED") != "false"
+	}
 
 	return cfg
 }
@@ -4,7 +4,13 @@ type contextKey string
 type sessionKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+const requestIDContextKey = contextKey("requestID")
+const loggerContextKey = contextKey("logger")
 
 const authenticatedUserID = sessionKey("authenticatedUserID")
 const redirectAfterLogin = sessionKey("redirectAfterLogin")
 const flash = sessionKey("flash")
+const newShareURL = sessionKey("newShareURL")
+const newCalendarURL = sessionKey("newCalendarURL")
+const createdWebhookSecret = sessionKey("createdWebhookSecret")
+const noteAuthor = sessionKey("noteAuthor")
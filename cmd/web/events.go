@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// eventsHeartbeatInterval is how often a comment is sent on idle event
+// streams, so that proxies don't close them.
+const eventsHeartbeatInterval = 15 * time.Second
+
+// contactEvents handles GET /events by streaming contact change events to the
+// client as Server-Sent Events, until the client disconnects or the server
+// shuts down. Each event's type is its event type, such as contact.updated,
+// and its data is the JSON payload also sent to webhooks. A resync event is
+// sent if events may have been missed.
+//
+// The route isn't wrapped in the dynamic middleware, since the session
+// middleware buffers the response until the handler returns.
+func (app *application) contactEvents(w http.ResponseWriter, r *http.Request) {
+	events, unsubscribe := app.events.Subscribe()
+	defer unsubscribe()
+
+	// Streams last longer than the server's WriteTimeout, so remove the
+	// deadline for this response.
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Ask the client to wait 5 seconds before reconnecting if the stream ends.
+	io.WriteString(w, "retry: 5000\n\n")
+	err = rc.Flush()
+	if err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, e.Data)
+		case <-heartbeat.C:
+			io.WriteString(w, ": heartbeat\n\n")
+		}
+
+		err = rc.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
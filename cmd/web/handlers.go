@@ -1,12 +1,27 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/kvnloughead/contacts-app/internal/ical"
 	"github.com/kvnloughead/contacts-app/internal/models"
+	"github.com/kvnloughead/contacts-app/internal/reminders"
 	"github.com/kvnloughead/contacts-app/internal/validator"
+	"github.com/kvnloughead/contacts-app/internal/vcard"
+	"github.com/kvnloughead/contacts-app/internal/webhooks"
 )
 
 //
@@ -17,10 +32,35 @@ func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+// contactsPageSize is the number of contacts loaded at a time on the home page.
+const contactsPageSize = 25
+
+// recentlyViewedLimit is the number of recently viewed contacts shown on the
+// home page.
+const recentlyViewedLimit = 10
+
 // Displays home page in response to GET /. If we were using http.ServeMux we
 // would have to check the URL, but with httprouter.Router, "/" is exclusive.
+//
+// Contacts are loaded a page at a time, according to the page query parameter.
+// When the end of the table is scrolled into view, HTMX requests the next
+// page, and only the rows are rendered.
+//
+// Above the table, favorite and recently viewed contacts are listed, either
+// most recently viewed first or most viewed first, according to the views
+// query parameter.
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	contacts, err := app.contacts.GetAll()
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	sort := r.URL.Query().Get("sort")
+	if _, ok := models.ContactSorts[sort]; !ok {
+		sort = "name"
+	}
+
+	contacts, metadata, err := app.contacts.GetPage(r.Context(), page, contactsPageSize, sort)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -28,6 +68,44 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Contacts = contacts
+	data.BulkFields = bulkFieldOptions()
+	data.Sort = sort
+	data.Pagination = pagination{Metadata: metadata}
+	if metadata.CurrentPage < metadata.LastPage {
+		data.Pagination.NextURL = fmt.Sprintf("/?page=%d&sort=%s", metadata.CurrentPage+1, url.QueryEscape(sort))
+	}
+
+	if isHTMX(r) {
+		app.renderFragment(w, r, http.StatusOK, "home.tmpl", "contact-rows", data)
+		return
+	}
+
+	// Reminders due by the end of today, including overdue ones, are shown
+	// above the table.
+	now := time.Now().UTC()
+	endOfToday := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	data.Reminders, err = app.reminders.GetDue(r.Context(), endOfToday)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.ViewOrder = r.URL.Query().Get("views")
+	if _, ok := models.ViewOrders[data.ViewOrder]; !ok {
+		data.ViewOrder = "recent"
+	}
+
+	data.Favorites, err = app.contacts.GetFavorites(r.Context(), data.ViewOrder)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.RecentlyViewed, err = app.contacts.GetRecentlyViewed(r.Context(), recentlyViewedLimit, data.ViewOrder)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	app.render(w, r, http.StatusOK, "home.tmpl", data)
 }
@@ -44,16 +122,240 @@ func (app *application) about(w http.ResponseWriter, r *http.Request) {
 
 // contactFormFields struct contains the form fields for the
 // /contacts/create or /contacts/edit forms.
+//
+// The edit form also contains the values of the contact at Version, which the
+// user started editing from, in the Base fields. These are used to show what
+// changed if there is an edit conflict.
 type contactFormFields struct {
 	ID                  int        `form:"id"`
 	First               string     `form:"first"`
 	Last                string     `form:"last"`
 	Phone               string     `form:"phone"`
 	Email               string     `form:"email"`
+	Company             string     `form:"company"`
+	Title               string     `form:"title"`
+	Department          string     `form:"department"`
+	Birthday            string     `form:"birthday"`
 	Version             int        `form:"version"`
+	BaseFirst           string     `form:"base_first"`
+	BaseLast            string     `form:"base_last"`
+	BasePhone           string     `form:"base_phone"`
+	BaseEmail           string     `form:"base_email"`
+	BaseCompany         string     `form:"base_company"`
+	BaseTitle           string     `form:"base_title"`
+	BaseDepartment      string     `form:"base_department"`
+	BaseBirthday        string     `form:"base_birthday"`
 	validator.Validator `form:"-"` // "-" tells formDecoder to ignore the field
 }
 
+// fieldConflict contains the values of a single field of a contact involved in
+// an edit conflict: the value the user started from, the value they submitted,
+// and the current value in the database.
+type fieldConflict struct {
+	Name    string
+	Label   string
+	Base    string
+	Yours   string
+	Current string
+}
+
+// Conflicting returns true if the user has to choose between their value and
+// the current value.
+func (c fieldConflict) Conflicting() bool {
+	return c.Yours != c.Current
+}
+
+// KeepYours returns true if the user's value should be selected by default,
+// which is the case if they changed the field. Otherwise the other user's
+// change is selected.
+func (c fieldConflict) KeepYours() bool {
+	return c.Yours != c.Base
+}
+
+// contactConflicts returns a fieldConflict for each field of the contact form,
+// in the order they appear in the form.
+func contactConflicts(base, yours, current models.Contact) []fieldConflict {
+	b, y, c := contactSummary(base), contactSummary(yours), contactSummary(current)
+
+	var conflicts []fieldConflict
+	for _, name := range []string{"first", "last", "phone", "email", "company", "title", "department", "birthday"} {
+		conflicts = append(conflicts, fieldConflict{
+			Name:    name,
+			Label:   contactFormFieldLabels[name],
+			Base:    b[name],
+			Yours:   y[name],
+			Current: c[name],
+		})
+	}
+	return conflicts
+}
+
+// validate checks all of the form's fields, adding an error for each invalid
+// field.
+func (form *contactFormFields) validate() {
+	form.CheckField(validator.NotBlank(form.First), "first", "This field can't be blank.")
+	form.CheckField(validator.MaxChars(form.First, 100), "first", "This can't contain more than 100 characters.")
+	form.CheckField(validator.NotBlank(form.Last), "last", "This field can't be blank.")
+	form.CheckField(validator.MaxChars(form.Last, 100), "last", "This can't contain more than 100 characters.")
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field can't be blank.")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Invalid email.")
+
+	form.CheckField(validator.NotBlank(form.Phone), "phone", "This field can't be blank.")
+	form.CheckField(validator.ValidatePhoneNumberInput(form.Phone), "phone", "Invalid phone number.")
+
+	// The company, title and department are optional.
+	form.Company = strings.TrimSpace(form.Company)
+	form.CheckField(validator.MaxChars(form.Company, 100), "company", "This can't contain more than 100 characters.")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This can't contain more than 100 characters.")
+	form.CheckField(validator.MaxChars(form.Department, 100), "department", "This can't contain more than 100 characters.")
+
+	// The birthday is also optional.
+	birthday, err := parseBirthday(form.Birthday)
+	form.CheckField(err == nil, "birthday", "Enter a date.")
+	form.CheckField(err != nil || birthday.Before(time.Now()), "birthday", "This can't be in the future.")
+}
+
+// birthdayLayout is the format of the value of a date input, and of birthdays
+// in the contact form.
+const birthdayLayout = "2006-01-02"
+
+// parseBirthday parses a birthday in birthdayLayout. A blank birthday is
+// parsed as the zero time.
+func parseBirthday(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(birthdayLayout, s, time.UTC)
+}
+
+// formatBirthday formats a birthday in birthdayLayout, or returns an empty
+// string if the birthday is zero.
+func formatBirthday(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(birthdayLayout)
+}
+
+// contactFromForm returns the contact described by the form. If the form names a
+// company, it is looked up by name when the contact is saved, and created if it
+// doesn't exist yet with the domain of the contact's email address. See
+// companyDomain.
+func contactFromForm(form contactFormFields) (models.Contact, error) {
+	birthday, err := parseBirthday(form.Birthday)
+	if err != nil {
+		return models.Contact{}, err
+	}
+
+	contact := models.Contact{
+		ID:            form.ID,
+		First:         form.First,
+		Last:          form.Last,
+		Phone:         form.Phone,
+		Email:         form.Email,
+		Company:       form.Company,
+		CompanyDomain: companyDomain(form.Email),
+		Title:         form.Title,
+		Department:    form.Department,
+		Birthday:      birthday,
+		Version:       int32(form.Version),
+	}
+
+	return contact, nil
+}
+
+// companyOptionsLimit is the number of companies suggested at a time by the
+// company field of the contact form.
+const companyOptionsLimit = 20
+
+// renderContactForm renders the create or edit page, along with the first
+// companies by name, which are suggested by the company field. The
+// suggestions are replaced as the field is typed. See companyOptions.
+func (app *application) renderContactForm(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
+	companies, err := app.companies.Search(r.Context(), "", companyOptionsLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.Companies = companies
+
+	app.render(w, r, status, page, data)
+}
+
+// relatedPickerLimit is the number of contacts offered at a time by the form
+// to add a relation on the edit page.
+const relatedPickerLimit = 20
+
+// renderContactEdit renders the edit page for the contact with the given ID.
+// Below the contact form, the page lists the contact's relations, with forms
+// to remove them or add a relation to another contact. The contacts offered
+// are those matching the related_q query parameter. See relatedCandidates.
+func (app *application) renderContactEdit(w http.ResponseWriter, r *http.Request, status int, id int, data templateData) {
+	relations, err := app.relations.GetForContact(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.RelatedQuery = r.URL.Query().Get("related_q")
+	data.Contacts, err = app.relatedCandidates(r, id, data.RelatedQuery)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Relations = relations
+	data.RelationTypes = models.RelationTypes
+
+	app.renderContactForm(w, r, status, "edit.tmpl", data)
+}
+
+// relatedCandidates returns up to relatedPickerLimit contacts whose names
+// match query, other than the contact with the given ID, to offer as its
+// relations.
+func (app *application) relatedCandidates(r *http.Request, id int, query string) ([]models.Contact, error) {
+	contacts, err := app.contacts.Search(r.Context(), query, relatedPickerLimit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	contacts = slices.DeleteFunc(contacts, func(c models.Contact) bool { return c.ID == id })
+	return contacts[:min(len(contacts), relatedPickerLimit)], nil
+}
+
+// contactRelatedOptions handles GET /contacts/relate/:id, which is sent by
+// HTMX as the search field of the edit page's relation form is typed. The
+// form's select input is rendered again with the contacts matching the q
+// query parameter.
+func (app *application) contactRelatedOptions(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	contacts, err := app.relatedCandidates(r, id, r.URL.Query().Get("related_q"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.renderFragment(w, r, http.StatusOK, "edit.tmpl", "related-options", contacts)
+}
+
+// contactFormFieldLabels maps the names of the contact form's fields to their
+// labels. See contactValidateField.
+var contactFormFieldLabels = map[string]string{
+	"first":      "First name",
+	"last":       "Last name",
+	"phone":      "Phone number",
+	"email":      "Email",
+	"company":    "Company",
+	"title":      "Job title",
+	"department": "Department",
+	"birthday":   "Birthday",
+}
+
 // View page for the contact with the given ID.
 // If there's no matching contact a 404 NotFound response is sent.
 func (app *application) contactView(w http.ResponseWriter, r *http.Request) {
@@ -64,7 +366,7 @@ func (app *application) contactView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	contact, err := app.contacts.Get(id)
+	contact, err := app.contacts.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -74,16 +376,66 @@ func (app *application) contactView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// HTMX requests come from the table on the home page, for example when
+	// inline editing is cancelled, so only the contact's row is rendered.
+	if isHTMX(r) {
+		app.renderFragment(w, r, http.StatusOK, "home.tmpl", "contact-row", contact)
+		return
+	}
+
+	// Failing to record the view shouldn't stop the contact being displayed, so
+	// the error is only logged.
+	err = app.contacts.RecordView(r.Context(), id)
+	if err != nil {
+		app.requestLogger(r).Error("recording contact view failed", "contact_id", id, "error", err.Error())
+	}
+
+	form := noteFormFields{
+		Kind:   models.NoteKindNote,
+		Author: app.sessionManager.GetString(r.Context(), string(noteAuthor)),
+	}
+	app.renderContactView(w, r, http.StatusOK, contact, form, reminderFormFields{})
+}
+
+// renderContactView renders the contact's page, with their relations, their
+// open reminders, their timeline of notes and interactions, and the given
+// forms to add to the timeline and reminders.
+func (app *application) renderContactView(w http.ResponseWriter, r *http.Request, status int, contact models.Contact, form noteFormFields, reminderForm reminderFormFields) {
+	relations, err := app.relations.GetForContact(r.Context(), contact.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	contactReminders, err := app.reminders.GetForContact(r.Context(), contact.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	notes, err := app.notes.GetForContact(r.Context(), contact.ID, timelineLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
 	data.Contact = contact
+	data.Relations = relations
+	data.Reminders = contactReminders
+	data.Recurrences = recurrenceOptions
+	data.ReminderForm = reminderForm
+	data.Notes = notes
+	data.NoteKinds = noteKindOptions()
+	data.Form = form
 
-	app.render(w, r, http.StatusOK, "view.tmpl", data)
+	app.render(w, r, status, "view.tmpl", data)
 }
 
 func (app *application) contactCreate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = contactFormFields{}
-	app.render(w, r, http.StatusOK, "create.tmpl", data)
+	app.renderContactForm(w, r, http.StatusOK, "create.tmpl", data)
 }
 
 /*
@@ -107,31 +459,31 @@ func (app *application) contactCreatePost(w http.ResponseWriter, r *http.Request
 	}
 
 	// Validate all form fields.
-	form.CheckField(validator.NotBlank(form.First), "first", "This field can't be blank.")
-	form.CheckField(validator.MaxChars(form.First, 100), "first", "This can't contain more than 100 characters.")
-	form.CheckField(validator.NotBlank(form.Last), "last", "This field can't be blank.")
-	form.CheckField(validator.MaxChars(form.Last, 100), "last", "This can't contain more than 100 characters.")
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field can't be blank.")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Invalid email.")
-
-	form.CheckField(validator.NotBlank(form.Phone), "phone", "This field can't be blank.")
-	form.CheckField(validator.ValidatePhoneNumberInput(form.Phone), "phone", "Invalid phone number.")
+	form.validate()
 
 	// If there are any validation errors, render the page again with the errors.
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
+		app.renderContactForm(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
+		return
+	}
+
+	contact, err := contactFromForm(form)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
 	// Insert new record or respond with a server error.
-	id, err := app.contacts.Insert(form.First, form.Last, form.Phone, form.Email)
+	id, err := app.contacts.Insert(r.Context(), contact)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	app.audit(r, models.AuditContactCreated, "contact", id, nil, contactSummary(contact))
+
 	// Assign text to session data with the key "flash". The data is stored in the
 	// request's context. If there is no current session, a new one will be created.
 	// The flash is added to our template data via the newTemplateData function.
@@ -141,8 +493,138 @@ func (app *application) contactCreatePost(w http.ResponseWriter, r *http.Request
 	http.Redirect(w, r, fmt.Sprintf("/contacts/view/%d", id), http.StatusSeeOther)
 }
 
+// maxImportSize is the maximum size in bytes of a vCard file uploaded to the
+// import form, and maxImportCards is the maximum number of vCards it may
+// contain, so that the import can be done in a single transaction within the
+// server's write timeout.
+const (
+	maxImportSize  = 1 << 20
+	maxImportCards = 100
+)
+
+// maxImportBodySize is the maximum size in bytes of a request to the import
+// form. It is larger than maxImportSize, so that a file that is a little too
+// large gets a form error rather than a 413 response.
+const maxImportBodySize = 10 << 20
+
+// importFormFields contains the errors of the vCard import form. The file
+// itself is read with r.FormFile.
+type importFormFields struct {
+	validator.Validator `form:"-"`
+}
+
+// contactImport handles GET /contacts/import by displaying a form to upload a
+// vCard file.
+func (app *application) contactImport(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = importFormFields{}
+	app.render(w, r, http.StatusOK, "import.tmpl", data)
+}
+
+/*
+contactImportPost handles POST /contacts/import by creating a contact for each
+vCard in the uploaded file, along with the relations from their RELATED
+properties, and redirecting to the home page. If the file is too large, can't
+be read, or contains too many cards, the form is rendered again with a 422
+status code.
+
+Cards that would be invalid in the contact form are skipped. The others are
+imported in a single transaction (see models.ContactModel.Import), so an error
+leaves nothing imported.
+*/
+func (app *application) contactImportPost(w http.ResponseWriter, r *http.Request) {
+	var form importFormFields
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		if !errors.Is(err, http.ErrMissingFile) {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		form.AddFieldError("file", "Choose a file to import.")
+	} else {
+		defer file.Close()
+		form.CheckField(header.Size <= maxImportSize, "file", "This file is larger than 1 MB.")
+	}
+
+	var cards []models.Contact
+	if form.Valid() {
+		cards, err = vcard.Read(file)
+		form.CheckField(err == nil, "file", "This isn't a vCard file.")
+		form.CheckField(err != nil || len(cards) > 0, "file", "This file doesn't contain any contacts.")
+		form.CheckField(len(cards) <= maxImportCards, "file",
+			fmt.Sprintf("This file contains more than %d contacts. Split it into smaller files.", maxImportCards))
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "import.tmpl", data)
+		return
+	}
+
+	var contacts []models.Contact
+	for _, card := range cards {
+		cardForm := contactFormFields{
+			First:      card.First,
+			Last:       card.Last,
+			Phone:      card.Phone,
+			Email:      card.Email,
+			Company:    card.Company,
+			Title:      card.Title,
+			Department: card.Department,
+		}
+		if !card.Birthday.IsZero() {
+			cardForm.Birthday = card.Birthday.Format(birthdayLayout)
+		}
+
+		cardForm.validate()
+		if !cardForm.Valid() {
+			continue
+		}
+
+		contact, err := contactFromForm(cardForm)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		contact.Related = card.Related
+		contacts = append(contacts, contact)
+	}
+
+	result, err := app.contacts.Import(r.Context(), contacts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	for _, contact := range result.Contacts {
+		app.audit(r, models.AuditContactCreated, "contact", contact.ID, nil, contactSummary(contact))
+	}
+	for _, rel := range result.Relations {
+		app.audit(r, models.AuditRelationAdded, "contact", rel.ContactID, nil, map[string]any{
+			"relation_id":   rel.ID,
+			"related_id":    rel.RelatedID,
+			"type":          rel.Type,
+			"bidirectional": false,
+		})
+	}
+
+	message := fmt.Sprintf("Imported %d contacts and %d relations.", len(result.Contacts), len(result.Relations))
+	if skipped := len(cards) - len(contacts); skipped > 0 {
+		message += fmt.Sprintf(" Skipped %d invalid contacts.", skipped)
+	}
+	if result.Unresolved > 0 {
+		message += fmt.Sprintf(" Skipped %d relations to unknown contacts.", result.Unresolved)
+	}
+	app.sessionManager.Put(r.Context(), string(flash), message)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 // contactEdit handles GET /contacts/edit/:id requests by displaying a form to
-// edit the contact.
+// edit the contact. For HTMX requests, the contact's row in the home page's
+// table is rendered as an inline form instead (see contactPut).
 func (app *application) contactEdit(w http.ResponseWriter, r *http.Request) {
 
 	id, err := app.readIdParam(r)
@@ -151,7 +633,7 @@ func (app *application) contactEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	contact, err := app.contacts.Get(id)
+	contact, err := app.contacts.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -161,18 +643,32 @@ func (app *application) contactEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isHTMX(r) {
+		form := contactFormFields{
+			ID:      contact.ID,
+			First:   contact.First,
+			Last:    contact.Last,
+			Phone:   contact.Phone,
+			Email:   contact.Email,
+			Version: int(contact.Version),
+		}
+		app.renderFragment(w, r, http.StatusOK, "home.tmpl", "contact-row-edit", form)
+		return
+	}
+
 	data := app.newTemplateData(r)
 	data.Contact = contact
 	data.Form = contactFormFields{}
 
-	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+	app.renderContactEdit(w, r, http.StatusOK, id, data)
 }
 
 // Updates a contact record. If successful, redirects the user to the
 // corresponding view page with a 303 status code.
 //
 // If one or more fields are invalid, the form is rendered again with a 422
-// status code, displaying the appropriate error messages.
+// status code, displaying the appropriate error messages. If another user has
+// updated the contact in the meantime, see contactEditConflict.
 //
 // Uses POST because HTML forms don't support PUT.
 func (app *application) contactEditPost(w http.ResponseWriter, r *http.Request) {
@@ -184,33 +680,39 @@ func (app *application) contactEditPost(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Validate all form fields.
-	form.CheckField(validator.NotBlank(form.First), "first", "This field can't be blank.")
-	form.CheckField(validator.MaxChars(form.First, 100), "first", "This can't contain more than 100 characters.")
-	form.CheckField(validator.NotBlank(form.Last), "last", "This field can't be blank.")
-	form.CheckField(validator.MaxChars(form.Last, 100), "last", "This can't contain more than 100 characters.")
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field can't be blank.")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Invalid email.")
-
-	form.CheckField(validator.NotBlank(form.Phone), "phone", "This field can't be blank.")
-	form.CheckField(validator.ValidatePhoneNumberInput(form.Phone), "phone", "Invalid phone number.")
+	form.validate()
 
 	// If there are any validation errors, render the page again with the errors.
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		app.renderContactEdit(w, r, http.StatusUnprocessableEntity, form.ID, data)
+		return
+	}
+
+	// Retrieve the contact as it is before the update, for the audit log.
+	before, err := app.contacts.Get(r.Context(), form.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
 		return
 	}
 
-	var contact = models.Contact{ID: form.ID, First: form.First, Last: form.Last, Phone: form.Phone, Email: form.Email, Version: int32(form.Version)}
+	contact, err := contactFromForm(form)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	// Update record or respond with a server error.
-	err = app.contacts.Update(&contact)
+	err = app.contacts.Update(r.Context(), &contact)
 	if err != nil {
 		switch {
 		case errors.Is(err, models.ErrEditConflict):
-			app.sessionManager.Put(r.Context(), "flash", "Another user has updated this contact. Please reload and try again.")
-			http.Redirect(w, r, fmt.Sprintf("/contacts/edit/%d", form.ID), http.StatusSeeOther)
+			app.contactEditConflict(w, r, form)
 			return
 		default:
 			app.serverError(w, r, err)
@@ -218,6 +720,8 @@ func (app *application) contactEditPost(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	app.audit(r, models.AuditContactUpdated, "contact", contact.ID, contactSummary(before), contactSummary(contact))
+
 	// Assign text to session data with the key "flash". The data is stored in the
 	// request's context. If there is no current session, a new one will be created.
 	// The flash is added to our template data via the newTemplateData function.
@@ -227,6 +731,55 @@ func (app *application) contactEditPost(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, fmt.Sprintf("/contacts/view/%d", form.ID), http.StatusSeeOther)
 }
 
+// contactEditConflict handles an edit conflict in contactEditPost, where
+// another user has updated the contact since the submitted form was loaded.
+// The edit form is rendered again with a 409 Conflict status, showing a
+// comparison of the value the user started from, the value they submitted and
+// the current value of each field, so that they can choose which to keep.
+//
+// The form's version and base values are set to the current contact's, so
+// that resubmitting the form updates the contact, unless it has changed again.
+// If the contact has been deleted, the user is redirected to the home page.
+func (app *application) contactEditConflict(w http.ResponseWriter, r *http.Request, form contactFormFields) {
+	current, err := app.contacts.Get(r.Context(), form.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), string(flash), "Another user has deleted this contact.")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// The birthdays have already been validated, except for the base, which is
+	// only displayed.
+	baseBirthday, _ := parseBirthday(form.BaseBirthday)
+	yourBirthday, _ := parseBirthday(form.Birthday)
+
+	base := models.Contact{First: form.BaseFirst, Last: form.BaseLast, Phone: form.BasePhone, Email: form.BaseEmail,
+		Company: form.BaseCompany, Title: form.BaseTitle, Department: form.BaseDepartment, Birthday: baseBirthday}
+	yours := models.Contact{First: form.First, Last: form.Last, Phone: form.Phone, Email: form.Email,
+		Company: form.Company, Title: form.Title, Department: form.Department, Birthday: yourBirthday}
+
+	form.Version = int(current.Version)
+	form.BaseFirst = current.First
+	form.BaseLast = current.Last
+	form.BasePhone = current.Phone
+	form.BaseEmail = current.Email
+	form.BaseCompany = current.Company
+	form.BaseTitle = current.Title
+	form.BaseDepartment = current.Department
+	form.BaseBirthday = formatBirthday(current.Birthday)
+
+	data := app.newTemplateData(r)
+	data.Contact = current
+	data.Form = form
+	data.Conflicts = contactConflicts(base, yours, current)
+
+	app.renderContactEdit(w, r, http.StatusConflict, form.ID, data)
+}
+
 func (app *application) contactDelete(w http.ResponseWriter, r *http.Request) {
 
 	id, err := app.readIdParam(r)
@@ -236,7 +789,7 @@ func (app *application) contactDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the contact from the database, if it exists.
-	contact, err := app.contacts.Get(id)
+	contact, err := app.contacts.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -258,6 +811,7 @@ func (app *application) contactDelete(w http.ResponseWriter, r *http.Request) {
 // a document with the supplied ID it
 //
 //   - removes it from the database
+//   - records the deletion in the audit log
 //   - flashes a success message
 //   - redirects user to the home page
 //
@@ -266,17 +820,1945 @@ func (app *application) contactDeletePost(w http.ResponseWriter, r *http.Request
 	id, err := app.readIdParam(r)
 	if err != nil {
 		app.notFound(w)
+		return
 	}
 
-	err = app.contacts.Delete(id)
+	// Retrieve the contact before deleting it, for the audit log.
+	contact, err := app.contacts.Get(r.Context(), id)
 	if err != nil {
-		if errors.Is(err, models.ErrRecordNotFound) {
+		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
 		}
-		app.serverError(w, r, err)
+		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Contact successfully deleted")
-
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	err = app.contacts.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditContactDeleted, "contact", id, contactSummary(contact), nil)
+
+	app.sessionManager.Put(r.Context(), "flash", "Contact successfully deleted")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// contactSummary returns a summary of the contact's fields, for recording the
+// state of the contact before and after a change in the audit log.
+func contactSummary(c models.Contact) map[string]string {
+	return map[string]string{
+		"first":      c.First,
+		"last":       c.Last,
+		"phone":      c.Phone,
+		"email":      c.Email,
+		"company":    c.Company,
+		"title":      c.Title,
+		"department": c.Department,
+		"birthday":   formatBirthday(c.Birthday),
+	}
+}
+
+// contactPut handles PUT /contacts/:id, sent by the inline edit form in the
+// home page's table. On success the updated row is rendered, and a message is
+// shown with the HX-Trigger header.
+//
+// If the form is invalid, or another user has updated the contact since the
+// form was loaded, the inline form is rendered again with the errors. In the
+// case of a conflict, the form is filled with the contact's current values.
+func (app *application) contactPut(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	var form contactFormFields
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.ID = id
+
+	form.validate()
+	if !form.Valid() {
+		app.renderFragment(w, r, http.StatusUnprocessableEntity, "home.tmpl", "contact-row-edit", form)
+		return
+	}
+
+	before, err := app.contacts.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// The inline form only has some of the contact's fields, so the others are
+	// kept as they were.
+	contact := before
+	contact.First = form.First
+	contact.Last = form.Last
+	contact.Phone = form.Phone
+	contact.Email = form.Email
+	contact.Version = int32(form.Version)
+
+	err = app.contacts.Update(r.Context(), &contact)
+	if err != nil {
+		if !errors.Is(err, models.ErrEditConflict) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		current := contactFormFields{
+			ID:      before.ID,
+			First:   before.First,
+			Last:    before.Last,
+			Phone:   before.Phone,
+			Email:   before.Email,
+			Version: int(before.Version),
+		}
+		current.AddNonFieldError("Another user has updated this contact. Their changes are shown here.")
+		app.renderFragment(w, r, http.StatusConflict, "home.tmpl", "contact-row-edit", current)
+		return
+	}
+
+	app.audit(r, models.AuditContactUpdated, "contact", contact.ID, contactSummary(before), contactSummary(contact))
+
+	setHXTrigger(w, "Contact successfully updated!")
+	app.renderFragment(w, r, http.StatusOK, "home.tmpl", "contact-row", contact)
+}
+
+// contactDeleteHX handles DELETE /contacts/:id, sent with HTMX from the home
+// page's table or the delete confirmation page.
+//
+// If the request targets the contact's row in the table, an empty response is
+// sent, which removes the row, and a message is shown with the HX-Trigger
+// header. Otherwise the success message is flashed and the client is
+// redirected to the home page with the HX-Redirect header.
+func (app *application) contactDeleteHX(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	contact, err := app.contacts.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.contacts.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditContactDeleted, "contact", id, contactSummary(contact), nil)
+
+	if r.Header.Get("HX-Target") == fmt.Sprintf("contact-%d", id) {
+		setHXTrigger(w, "Contact successfully deleted")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), string(flash), "Contact successfully deleted")
+	w.Header().Set("HX-Redirect", "/")
+	w.WriteHeader(http.StatusOK)
+}
+
+// contactValidateField handles POST /contacts/validate/:field, which is sent
+// by HTMX when a field of the create or edit form changes. The form is
+// validated, and just the named field is rendered again, with its error if it
+// has one.
+func (app *application) contactValidateField(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("field")
+	label, ok := contactFormFieldLabels[name]
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	var form contactFormFields
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+
+	values := map[string]string{
+		"first":      form.First,
+		"last":       form.Last,
+		"phone":      form.Phone,
+		"email":      form.Email,
+		"company":    form.Company,
+		"title":      form.Title,
+		"department": form.Department,
+		"birthday":   form.Birthday,
+	}
+	field := formField{Name: name, Label: label, Value: values[name], Error: form.FieldErrors[name]}
+
+	fragment := "contact-field"
+	if name == "birthday" {
+		fragment = "date-field"
+	}
+	app.renderFragment(w, r, http.StatusOK, "create.tmpl", fragment, field)
+}
+
+// favoriteFormFields struct contains the form fields for the star buttons
+// that mark a contact as a favorite.
+type favoriteFormFields struct {
+	Favorite bool `form:"favorite"`
+}
+
+// contactFavoritePost handles POST /contacts/favorite/:id by marking the
+// contact as a favorite, or unmarking it. HTMX requests come from the star in
+// the contact's row on the home page, and only the star is rendered again.
+// Otherwise the user is redirected to the contact's page.
+func (app *application) contactFavoritePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	var form favoriteFormFields
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	contact, err := app.contacts.SetFavorite(r.Context(), id, form.Favorite)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if isHTMX(r) {
+		app.renderFragment(w, r, http.StatusOK, "home.tmpl", "favorite-star", contact)
+		return
+	}
+
+	msg := fmt.Sprintf("%s %s added to favorites.", contact.First, contact.Last)
+	if !contact.Favorite {
+		msg = fmt.Sprintf("%s %s removed from favorites.", contact.First, contact.Last)
+	}
+	app.sessionManager.Put(r.Context(), string(flash), msg)
+
+	http.Redirect(w, r, fmt.Sprintf("/contacts/view/%d", id), http.StatusSeeOther)
+}
+
+//
+// Note handlers
+//
+
+// timelineLimit is the number of most recent notes displayed on a contact's
+// page.
+const timelineLimit = 100
+
+// occurredLayout is the format of the value of a datetime-local input.
+const occurredLayout = "2006-01-02T15:04"
+
+// formLocation returns the time zone named by a form's timezone field, in
+// which the form's datetime-local inputs are read. The field is set to the
+// browser's IANA time zone by main.js. Without JavaScript it is blank, and UTC
+// is used.
+func formLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	// "Local" would be the server's time zone, not the browser's.
+	if loc == time.Local {
+		return nil, fmt.Errorf("unknown time zone %q", timezone)
+	}
+	return loc, nil
+}
+
+// noteFormFields struct contains the form fields for the form to add a note
+// to a contact's timeline. Occurred is in occurredLayout, in the time zone in
+// the Timezone field, and may be blank for the current time.
+type noteFormFields struct {
+	Kind                string `form:"kind"`
+	Occurred            string `form:"occurred"`
+	Timezone            string `form:"timezone"`
+	Author              string `form:"author"`
+	Body                string `form:"body"`
+	validator.Validator `form:"-"`
+}
+
+// noteKindLabels maps each kind of note to its label.
+var noteKindLabels = map[string]string{
+	models.NoteKindNote:    "Note",
+	models.NoteKindCall:    "Call",
+	models.NoteKindMeeting: "Meeting",
+	models.NoteKindEmail:   "Email",
+}
+
+// noteKindOptions returns the kinds of note, for the select input on the
+// contact page.
+func noteKindOptions() []formField {
+	options := make([]formField, len(models.NoteKinds))
+	for i, kind := range models.NoteKinds {
+		options[i] = formField{Name: kind, Label: noteKindLabels[kind]}
+	}
+	return options
+}
+
+// validate checks the form's fields, and returns the time the note occurred,
+// which is zero if the field is blank.
+func (form *noteFormFields) validate() time.Time {
+	form.Author = strings.TrimSpace(form.Author)
+	form.CheckField(validator.PermittedValue(form.Kind, models.NoteKinds...), "kind", "Choose a type.")
+	form.CheckField(validator.MaxChars(form.Author, 100), "author", "This can't contain more than 100 characters.")
+	form.CheckField(validator.NotBlank(form.Body), "body", "This field can't be blank.")
+	form.CheckField(validator.MaxChars(form.Body, 10000), "body", "This can't contain more than 10000 characters.")
+
+	if form.Occurred == "" {
+		return time.Time{}
+	}
+
+	loc, err := formLocation(form.Timezone)
+	if err != nil {
+		form.AddFieldError("occurred", "Unknown time zone.")
+		return time.Time{}
+	}
+
+	occurred, err := time.ParseInLocation(occurredLayout, form.Occurred, loc)
+	form.CheckField(err == nil, "occurred", "Enter a date and time.")
+	form.CheckField(err != nil || occurred.Before(time.Now().Add(time.Minute)), "occurred", "This can't be in the future.")
+	return occurred.UTC()
+}
+
+// contactNotePost handles POST /contacts/notes/:id by adding a note or logged
+// interaction to the contact's timeline, and redirecting back to the
+// contact's page. If the form is invalid, the page is rendered again with the
+// errors.
+//
+// The author is remembered in the session, to fill in the next form.
+func (app *application) contactNotePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	var form noteFormFields
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	contact, err := app.contacts.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	occurred := form.validate()
+	if !form.Valid() {
+		app.renderContactView(w, r, http.StatusUnprocessableEntity, contact, form, reminderFormFields{})
+		return
+	}
+
+	note := models.Note{ContactID: id, Kind: form.Kind, Occurred: occurred, Author: form.Author, Body: form.Body}
+	noteID, err := app.notes.Insert(r.Context(), note)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditNoteAdded, "contact", id, nil, noteSummary(noteID, note))
+
+	app.sessionManager.Put(r.Context(), string(noteAuthor), form.Author)
+	app.sessionManager.Put(r.Context(), string(flash), fmt.Sprintf("%s added to the timeline.", noteKindLabels[form.Kind]))
+
+	http.Redirect(w, r, fmt.Sprintf("/contacts/view/%d", id), http.StatusSeeOther)
+}
+
+// noteDeletePost handles POST /notes/delete/:id by deleting the note and
+// redirecting to its contact's page.
+func (app *application) noteDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	note, err := app.notes.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.notes.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditNoteDeleted, "contact", note.ContactID, noteSummary(id, note), nil)
+
+	app.sessionManager.Put(r.Context(), string(flash), fmt.Sprintf("%s deleted.", noteKindLabels[note.Kind]))
+
+	http.Redirect(w, r, fmt.Sprintf("/contacts/view/%d", note.ContactID), http.StatusSeeOther)
+}
+
+// noteSummary returns a summary of the note, for the audit log.
+func noteSummary(id int, n models.Note) map[string]any {
+	return map[string]any{
+		"note_id":  id,
+		"kind":     n.Kind,
+		"occurred": n.Occurred,
+		"author":   n.Author,
+		"body":     n.Body,
+	}
+}
+
+//
+// Reminder handlers
+//
+
+// reminderFormFields struct contains the form fields for the form to add a
+// reminder on a contact's page. Due is in occurredLayout, in the time zone in
+// the Timezone field (see formLocation).
+type reminderFormFields struct {
+	Due                 string `form:"due"`
+	Timezone            string `form:"timezone"`
+	Note                string `form:"note"`
+	Recurrence          string `form:"recurrence"`
+	validator.Validator `form:"-"`
+}
+
+// recurrenceOptions are the recurrence rules offered by the reminder form.
+var recurrenceOptions = []formField{
+	{Name: "", Label: "Doesn't repeat"},
+	{Name: "FREQ=DAILY", Label: "Daily"},
+	{Name: "FREQ=WEEKLY", Label: "Weekly"},
+	{Name: "FREQ=WEEKLY;INTERVAL=2", Label: "Every 2 weeks"},
+	{Name: "FREQ=MONTHLY", Label: "Monthly"},
+	{Name: "FREQ=MONTHLY;INTERVAL=3", Label: "Every 3 months"},
+	{Name: "FREQ=YEARLY", Label: "Yearly"},
+}
+
+// validate checks the form's fields, and returns the time the reminder is
+// due. A due time in the past is allowed, and fires straight away.
+func (form *reminderFormFields) validate() time.Time {
+	form.Note = strings.TrimSpace(form.Note)
+	form.CheckField(validator.MaxChars(form.Note, 500), "note", "This can't contain more than 500 characters.")
+
+	_, err := reminders.ParseRule(form.Recurrence)
+	form.CheckField(err == nil, "recurrence", "Choose how often this repeats.")
+
+	loc, err := formLocation(form.Timezone)
+	if err != nil {
+		form.AddFieldError("due", "Unknown time zone.")
+		return time.Time{}
+	}
+
+	due, err := time.ParseInLocation(occurredLayout, form.Due, loc)
+	form.CheckField(err == nil, "due", "Enter a date and time.")
+	return due.UTC()
+}
+
+// contactReminderPost handles POST /contacts/reminders/:id by adding a
+// reminder for the contact, and redirecting back to the contact's page. If
+// the form is invalid, the page is rendered again with the errors.
+func (app *application) contactReminderPost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	var form reminderFormFields
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	contact, err := app.contacts.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	due := form.validate()
+	if !form.Valid() {
+		noteForm := noteFormFields{
+			Kind:   models.NoteKindNote,
+			Author: app.sessionManager.GetString(r.Context(), string(noteAuthor)),
+		}
+		app.renderContactView(w, r, http.StatusUnprocessableEntity, contact, noteForm, form)
+		return
+	}
+
+	reminder := models.Reminder{ContactID: id, Due: due, Note: form.Note, Recurrence: form.Recurrence}
+	reminderID, err := app.reminders.Insert(r.Context(), reminder)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditReminderAdded, "contact", id, nil, reminderSummary(reminderID, reminder))
+
+	app.sessionManager.Put(r.Context(), string(flash), fmt.Sprintf("Reminder set for %s.", humanDate(due)))
+
+	http.Redirect(w, r, fmt.Sprintf("/contacts/view/%d", id), http.StatusSeeOther)
+}
+
+// reminderRedirectURL returns the URL to redirect to after completing or
+// deleting a reminder from a form. The forms on the home page send from=home,
+// and the others return to the contact's page.
+func reminderRedirectURL(r *http.Request, reminder models.Reminder) string {
+	if r.PostForm.Get("from") == "home" {
+		return "/"
+	}
+	return fmt.Sprintf("/contacts/view/%d", reminder.ContactID)
+}
+
+// reminderDonePost handles POST /reminders/done/:id by completing the
+// reminder. A recurring reminder moves to its next occurrence after now,
+// skipping any that were missed, and other reminders are marked as done.
+func (app *application) reminderDonePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	reminder, err := app.reminders.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	rule, err := reminders.ParseRule(reminder.Recurrence)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	next := rule.NextAfter(reminder.Due, time.Now())
+
+	err = app.reminders.Complete(r.Context(), id, next)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	after := reminderSummary(id, reminder)
+	after["next"] = next
+	app.audit(r, models.AuditReminderCompleted, "contact", reminder.ContactID, reminderSummary(id, reminder), after)
+
+	msg := "Reminder done."
+	if !next.IsZero() {
+		msg = fmt.Sprintf("Reminder done. The next one is due %s.", humanDate(next))
+	}
+	app.sessionManager.Put(r.Context(), string(flash), msg)
+
+	http.Redirect(w, r, reminderRedirectURL(r, reminder), http.StatusSeeOther)
+}
+
+// reminderDeletePost handles POST /reminders/delete/:id by deleting the
+// reminder, including any future occurrences.
+func (app *application) reminderDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	reminder, err := app.reminders.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.reminders.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditReminderDeleted, "contact", reminder.ContactID, reminderSummary(id, reminder), nil)
+
+	app.sessionManager.Put(r.Context(), string(flash), "Reminder deleted.")
+
+	http.Redirect(w, r, reminderRedirectURL(r, reminder), http.StatusSeeOther)
+}
+
+// reminderSummary returns a summary of the reminder, for the audit log.
+func reminderSummary(id int, r models.Reminder) map[string]any {
+	return map[string]any{
+		"reminder_id": id,
+		"due":         r.Due,
+		"note":        r.Note,
+		"recurrence":  r.Recurrence,
+	}
+}
+
+// notificationLimit is the number of most recent notifications displayed.
+const notificationLimit = 100
+
+// notificationList handles GET /notifications by displaying the most recent
+// notifications. Unread notifications are highlighted.
+func (app *application) notificationList(w http.ResponseWriter, r *http.Request) {
+	notifications, err := app.notifications.GetRecent(r.Context(), notificationLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Notifications = notifications
+	app.render(w, r, http.StatusOK, "notifications.tmpl", data)
+}
+
+// notificationReadPost handles POST /notifications/read by marking all
+// notifications as read, and redirecting back to the notifications page.
+func (app *application) notificationReadPost(w http.ResponseWriter, r *http.Request) {
+	err := app.notifications.MarkAllRead(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+//
+// Calendar handlers
+//
+
+// accountView handles GET /account/view by displaying the account page, with
+// the calendar feed's settings. The feed's URL is only shown straight after
+// its token is rotated, since only a hash of the token is stored.
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+	created, err := app.calendar.Created(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.CalendarCreated = created
+	data.CalendarURL = app.sessionManager.PopString(r.Context(), string(newCalendarURL))
+
+	app.render(w, r, http.StatusOK, "account.tmpl", data)
+}
+
+// calendarRotatePost handles POST /account/calendar/rotate by replacing the
+// calendar feed's token, so that the previous feed URL stops working, and
+// redirecting to the account page to show the new URL.
+func (app *application) calendarRotatePost(w http.ResponseWriter, r *http.Request) {
+	token, err := app.calendar.Rotate(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.audit(r, models.AuditCalendarRotated, "calendar", 0, nil, nil)
+
+	app.sessionManager.Put(r.Context(), string(newCalendarURL), app.absoluteURL("/calendar/"+token+".ics"))
+	app.sessionManager.Put(r.Context(), string(flash), "New calendar link created! Copy it now, it won't be shown again.")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+// calendarFeed handles GET /calendar/:token.ics by writing an iCalendar feed
+// of all contacts' birthdays and all open reminders, for calendar apps to
+// subscribe to. It doesn't require a session, since calendar apps don't send
+// cookies, so the token authenticates the request. If the token doesn't match
+// the current one, a 404 response is sent.
+func (app *application) calendarFeed(w http.ResponseWriter, r *http.Request) {
+	// httprouter parameters extend to the next slash, so the parameter includes
+	// the extension.
+	token, ok := strings.CutSuffix(httprouter.ParamsFromContext(r.Context()).ByName("token"), ".ics")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	err := app.calendar.Authenticate(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	contacts, err := app.contacts.GetAll(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	openReminders, err := app.reminders.GetOpen(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	feed := ical.Feed{
+		Name:      "Contact.app",
+		BaseURL:   app.absoluteURL(""),
+		Contacts:  contacts,
+		Reminders: openReminders,
+	}
+
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	err = ical.Write(w, feed, time.Now())
+	if err != nil {
+		app.requestLogger(r).Error("writing calendar feed failed", "error", err.Error())
+	}
+}
+
+//
+// Relation handlers
+//
+
+// relationFormFields struct contains the form fields for the forms to add and
+// remove relations on the contact edit page. ContactID is only used by the
+// remove form, to redirect back to the page it was sent from.
+type relationFormFields struct {
+	ContactID           int    `form:"contact_id"`
+	RelatedID           int    `form:"related_id"`
+	Type                string `form:"type"`
+	Bidirectional       bool   `form:"bidirectional"`
+	validator.Validator `form:"-"`
+}
+
+// contactRelatePost handles POST /contacts/relate/:id by recording that the
+// selected contact is the contact's relation of the selected type, and
+// redirecting back to the contact's edit page.
+//
+// The form only has select inputs and a checkbox, so an invalid form can only
+// be sent by a client other than the edit page, and gets a 400 response.
+func (app *application) contactRelatePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	var form relationFormFields
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	types := make([]string, len(models.RelationTypes))
+	for i, t := range models.RelationTypes {
+		types[i] = t.Name
+	}
+	form.CheckField(validator.PermittedValue(form.Type, types...), "type", "Choose a relation.")
+	form.CheckField(form.RelatedID > 0 && form.RelatedID != id, "related_id", "Choose another contact.")
+
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	relationID, err := app.relations.Insert(r.Context(), id, form.RelatedID, form.Type, form.Bidirectional)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateRelation):
+			app.sessionManager.Put(r.Context(), string(flash), "These contacts are already related in that way.")
+			http.Redirect(w, r, fmt.Sprintf("/contacts/edit/%d", id), http.StatusSeeOther)
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditRelationAdded, "contact", id, nil, map[string]any{
+		"relation_id":   relationID,
+		"related_id":    form.RelatedID,
+		"type":          form.Type,
+		"bidirectional": form.Bidirectional,
+	})
+
+	app.sessionManager.Put(r.Context(), string(flash), "Relation added!")
+
+	http.Redirect(w, r, fmt.Sprintf("/contacts/edit/%d", id), http.StatusSeeOther)
+}
+
+// contactUnrelatePost handles POST /contacts/unrelate/:id by removing the
+// relation with the given ID from both of the contacts it relates, and
+// redirecting back to the edit page of the contact in the form.
+func (app *application) contactUnrelatePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	var form relationFormFields
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	relation, err := app.relations.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.relations.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditRelationRemoved, "contact", relation.ContactID, map[string]any{
+		"relation_id":   relation.ID,
+		"related_id":    relation.RelatedID,
+		"type":          relation.Type,
+		"bidirectional": relation.Bidirectional,
+	}, nil)
+
+	app.sessionManager.Put(r.Context(), string(flash), "Relation removed.")
+
+	// The form may have been sent from either contact's page.
+	contactID := relation.ContactID
+	if form.ContactID == relation.RelatedID {
+		contactID = relation.RelatedID
+	}
+	http.Redirect(w, r, fmt.Sprintf("/contacts/edit/%d", contactID), http.StatusSeeOther)
+}
+
+//
+// Bulk action handlers
+//
+
+// Actions that can be applied to the selected contacts on the home page.
+const (
+	bulkDelete      = "delete"
+	bulkTag         = "tag"
+	bulkSetField    = "set_field"
+	bulkExportVCard = "export_vcard"
+	bulkExportCSV   = "export_csv"
+)
+
+// bulkFormFields struct contains the form fields for the bulk actions form on
+// the home page, and its confirmation page. Tag is only used by the tag
+// action, and Field and Value by the set_field action.
+//
+// Versions is only set by the confirmation page, and contains the version of
+// each contact in IDs at the time the page was rendered.
+type bulkFormFields struct {
+	IDs                 []int  `form:"ids"`
+	Versions            []int  `form:"versions"`
+	Action              string `form:"action"`
+	Tag                 string `form:"tag"`
+	Field               string `form:"field"`
+	Value               string `form:"value"`
+	validator.Validator `form:"-"`
+}
+
+// validate checks the action and the fields it uses.
+func (form *bulkFormFields) validate() {
+	form.CheckField(validator.PermittedValue(form.Action, bulkDelete, bulkTag, bulkSetField, bulkExportVCard, bulkExportCSV),
+		"action", "Choose an action.")
+
+	switch form.Action {
+	case bulkTag:
+		form.Tag = strings.TrimSpace(form.Tag)
+		form.CheckField(validator.NotBlank(form.Tag), "tag", "This field can't be blank.")
+		form.CheckField(validator.MaxChars(form.Tag, 50), "tag", "This can't contain more than 50 characters.")
+	case bulkSetField:
+		_, ok := models.BulkFields[form.Field]
+		form.CheckField(ok, "field", "Choose a field.")
+		form.CheckField(validator.NotBlank(form.Value), "value", "This field can't be blank.")
+		form.CheckField(validator.MaxChars(form.Value, 100), "value", "This can't contain more than 100 characters.")
+		if form.Field == "phone" {
+			form.CheckField(validator.ValidatePhoneNumberInput(form.Value), "value", "Invalid phone number.")
+		}
+	}
+}
+
+// dedupe removes repeated contact IDs from the form, along with their
+// versions if they are set, keeping the first of each. Otherwise a contact
+// listed twice would be counted as an edit conflict by the bulk actions.
+func (form *bulkFormFields) dedupe() {
+	seen := make(map[int]bool, len(form.IDs))
+	var ids, versions []int
+	for i, id := range form.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		if i < len(form.Versions) {
+			versions = append(versions, form.Versions[i])
+		}
+	}
+	form.IDs, form.Versions = ids, versions
+}
+
+// checkTagLimit checks that the tag action wouldn't give any of the contacts
+// more than models.MaxTags tags.
+func (form *bulkFormFields) checkTagLimit(contacts []models.Contact) {
+	if form.Action != bulkTag {
+		return
+	}
+
+	full := 0
+	for _, c := range contacts {
+		if len(appendTag(c.Tags, form.Tag)) > models.MaxTags {
+			full++
+		}
+	}
+	form.CheckField(full == 0, "tag",
+		fmt.Sprintf("%d of these contacts already have the maximum of %d tags.", full, models.MaxTags))
+}
+
+// FieldLabel returns the label of the field set by the set_field action. It
+// is called from bulk.tmpl.
+func (form bulkFormFields) FieldLabel() string {
+	return contactFormFieldLabels[form.Field]
+}
+
+// bulkFieldOptions returns the fields that can be set in bulk, for the select
+// input on the home and confirmation pages.
+func bulkFieldOptions() []formField {
+	var options []formField
+	for _, name := range []string{"first", "last", "phone", "email", "company", "title", "department"} {
+		if _, ok := models.BulkFields[name]; ok {
+			options = append(options, formField{Name: name, Label: contactFormFieldLabels[name]})
+		}
+	}
+	return options
+}
+
+// contactBulkPost handles POST /contacts/bulk, the bulk actions form on the
+// home page. Exports are sent as downloads immediately. For the other actions,
+// a confirmation page is rendered listing the selected contacts, which posts
+// to /contacts/bulk/confirm.
+func (app *application) contactBulkPost(w http.ResponseWriter, r *http.Request) {
+	var form bulkFormFields
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.dedupe()
+	if len(form.IDs) == 0 {
+		app.sessionManager.Put(r.Context(), string(flash), "Select at least one contact.")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	contacts, err := app.contacts.GetMany(r.Context(), form.IDs)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form.validate()
+	form.checkTagLimit(contacts)
+	if !form.Valid() {
+		app.renderBulkConfirmation(w, r, http.StatusUnprocessableEntity, form, contacts)
+		return
+	}
+
+	switch form.Action {
+	case bulkExportVCard:
+		contacts, err = app.withRelations(r, contacts)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", vcard.ContentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "contacts.vcf"}))
+		err = vcard.Write(w, contacts...)
+	case bulkExportCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "contacts.csv"}))
+		err = writeContactsCSV(w, contacts)
+	default:
+		app.renderBulkConfirmation(w, r, http.StatusOK, form, contacts)
+		return
+	}
+
+	// The headers have been sent by the time an export fails, so the error is
+	// logged rather than sent as a response.
+	if err != nil {
+		app.requestLogger(r).Error("contact export failed", "error", err.Error())
+	}
+}
+
+// contactBulkConfirmPost handles POST /contacts/bulk/confirm by applying the
+// action to the contacts listed on the confirmation page, in a single
+// transaction.
+//
+// If any of the contacts has changed or been deleted since the confirmation
+// page was rendered, nothing is changed, and the page is rendered again with
+// the contacts' current values so that the user can review them.
+func (app *application) contactBulkConfirmPost(w http.ResponseWriter, r *http.Request) {
+	var form bulkFormFields
+	err := app.decodePostForm(r, &form)
+	if err != nil || len(form.IDs) == 0 || len(form.IDs) != len(form.Versions) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.dedupe()
+
+	before, err := app.contacts.GetMany(r.Context(), form.IDs)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form.validate()
+	form.checkTagLimit(before)
+	// Exports don't change anything, so they aren't confirmed.
+	form.CheckField(form.Action != bulkExportVCard && form.Action != bulkExportCSV, "action", "Choose an action.")
+	if !form.Valid() {
+		app.renderBulkConfirmation(w, r, http.StatusUnprocessableEntity, form, before)
+		return
+	}
+
+	contacts := make([]models.Contact, len(form.IDs))
+	for i, id := range form.IDs {
+		contacts[i] = models.Contact{ID: id, Version: int32(form.Versions[i])}
+	}
+
+	var message string
+	switch form.Action {
+	case bulkDelete:
+		err = app.contacts.DeleteMany(r.Context(), contacts)
+		message = fmt.Sprintf("%d contacts deleted.", len(contacts))
+	case bulkTag:
+		err = app.contacts.AddTagMany(r.Context(), contacts, form.Tag)
+		message = fmt.Sprintf("%d contacts tagged %q.", len(contacts), form.Tag)
+	case bulkSetField:
+		err = app.contacts.SetFieldMany(r.Context(), contacts, form.Field, form.Value)
+		message = fmt.Sprintf("%d contacts updated.", len(contacts))
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrEditConflict) {
+			form.AddNonFieldError("Some of these contacts have changed or been deleted since you selected them. Review them and confirm again.")
+			app.renderBulkConfirmation(w, r, http.StatusConflict, form, before)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	for _, c := range before {
+		switch form.Action {
+		case bulkDelete:
+			app.audit(r, models.AuditContactDeleted, "contact", c.ID, contactSummary(c), nil)
+		case bulkTag:
+			app.audit(r, models.AuditContactUpdated, "contact", c.ID,
+				map[string]any{"tags": c.Tags}, map[string]any{"tags": appendTag(c.Tags, form.Tag)})
+		case bulkSetField:
+			after := contactSummary(c)
+			after[form.Field] = form.Value
+			app.audit(r, models.AuditContactUpdated, "contact", c.ID, contactSummary(c), after)
+		}
+	}
+
+	app.sessionManager.Put(r.Context(), string(flash), message)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// renderBulkConfirmation renders the confirmation page for a bulk action on
+// the contacts. The contacts' IDs and current versions are included in the
+// page's form.
+func (app *application) renderBulkConfirmation(w http.ResponseWriter, r *http.Request, status int, form bulkFormFields, contacts []models.Contact) {
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Contacts = contacts
+	data.BulkFields = bulkFieldOptions()
+
+	app.render(w, r, status, "bulk.tmpl", data)
+}
+
+// appendTag returns tags with tag added, unless it's already present.
+func appendTag(tags []string, tag string) []string {
+	if slices.Contains(tags, tag) {
+		return tags
+	}
+	return append(slices.Clip(tags), tag)
+}
+
+// withRelations returns the contacts with their Related fields set.
+func (app *application) withRelations(r *http.Request, contacts []models.Contact) ([]models.Contact, error) {
+	ids := make([]int, len(contacts))
+	for i, c := range contacts {
+		ids[i] = c.ID
+	}
+
+	relations, err := app.relations.GetForContacts(r.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range contacts {
+		contacts[i].Related = relations[contacts[i].ID]
+	}
+	return contacts, nil
+}
+
+// writeContactsCSV writes the contacts to w as CSV, with a header row. Tags
+// are separated by semicolons.
+func writeContactsCSV(w io.Writer, contacts []models.Contact) error {
+	cw := csv.NewWriter(w)
+
+	cw.Write([]string{"id", "first", "last", "phone", "email", "tags", "company", "title", "department", "birthday"})
+	for _, c := range contacts {
+		cw.Write([]string{strconv.Itoa(c.ID), c.First, c.Last, c.Phone, c.Email, strings.Join(c.Tags, ";"),
+			c.Company, c.Title, c.Department, formatBirthday(c.Birthday)})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+//
+// Share handlers
+//
+
+// shareFormFields struct contains the form fields for the share form on the
+// contact view page.
+type shareFormFields struct {
+	ExpiresDays         int `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+// contactSharePost handles POST /contacts/share/:id by creating a public link
+// to the contact, which expires after the number of days chosen in the form.
+//
+// The link's token can't be recovered after it is created, so the link is
+// stored in the session and displayed once on the shares page.
+func (app *application) contactSharePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	var form shareFormFields
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.PermittedValue(form.ExpiresDays, 1, 7, 30), "expires", "Links can expire after 1, 7 or 30 days.")
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Verify that the contact exists before sharing it.
+	_, err = app.contacts.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	shareID, token, err := app.shares.Insert(r.Context(), id, time.Duration(form.ExpiresDays)*24*time.Hour)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.audit(r, models.AuditShareCreated, "share", shareID, nil,
+		map[string]any{"contact_id": id, "expires_days": form.ExpiresDays})
+
+	app.sessionManager.Put(r.Context(), string(newShareURL), app.absoluteURL("/s/"+token))
+	app.sessionManager.Put(r.Context(), string(flash), "Share link created! Copy it now, it won't be shown again.")
+
+	http.Redirect(w, r, "/shares", http.StatusSeeOther)
+}
+
+// shareList handles GET /shares by displaying all active share links, along with
+// how many times each has been accessed.
+func (app *application) shareList(w http.ResponseWriter, r *http.Request) {
+	shares, err := app.shares.GetAllActive(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Shares = shares
+	data.ShareURL = app.sessionManager.PopString(r.Context(), string(newShareURL))
+
+	app.render(w, r, http.StatusOK, "shares.tmpl", data)
+}
+
+// shareRevokePost handles POST /shares/revoke/:id by revoking the share link,
+// so that it can no longer be used to view the contact.
+func (app *application) shareRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	err = app.shares.Revoke(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditShareRevoked, "share", id, nil, nil)
+
+	app.sessionManager.Put(r.Context(), string(flash), "Share link revoked.")
+
+	http.Redirect(w, r, "/shares", http.StatusSeeOther)
+}
+
+// sharedContact retrieves the contact for the share token in the URL, and
+// sets headers that prevent the public page from being cached or indexed. If
+// the token is invalid, expired or revoked, a 404 response is sent and ok is
+// false.
+func (app *application) sharedContact(w http.ResponseWriter, r *http.Request) (contact models.Contact, token string, ok bool) {
+	token = httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	contact, err := app.shares.GetContact(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.Contact{}, "", false
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	return contact, token, true
+}
+
+// sharedContactView handles GET /s/:token by displaying a read-only page for
+// the shared contact. It doesn't require a session.
+func (app *application) sharedContactView(w http.ResponseWriter, r *http.Request) {
+	contact, token, ok := app.sharedContact(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Contact = contact
+	data.ShareToken = token
+
+	app.render(w, r, http.StatusOK, "shared.tmpl", data)
+}
+
+// sharedContactVCard handles GET /s/:token/contact.vcf by sending the shared
+// contact as a vCard download.
+func (app *application) sharedContactVCard(w http.ResponseWriter, r *http.Request) {
+	contact, _, ok := app.sharedContact(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", vcard.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": vcard.Filename(contact)}))
+
+	err := vcard.Write(w, contact)
+	if err != nil {
+		app.requestLogger(r).Error(err.Error())
+	}
+}
+
+//
+// Audit handlers
+//
+
+// auditPageSize is the number of events displayed per page of the audit log.
+const auditPageSize = 50
+
+// auditFilterForm contains the query string parameters used to filter the
+// audit log. Dates are in YYYY-MM-DD format, and both are inclusive.
+type auditFilterForm struct {
+	Action              string `form:"action"`
+	TargetID            int    `form:"target"`
+	From                string `form:"from"`
+	To                  string `form:"to"`
+	Page                int    `form:"page"`
+	validator.Validator `form:"-"`
+}
+
+// readAuditFilter decodes and validates the audit log filters in the request's
+// query string. The returned form contains any validation errors, and the
+// returned filter should only be used if the form is valid.
+func (app *application) readAuditFilter(r *http.Request) (auditFilterForm, models.AuditFilter) {
+	var form auditFilterForm
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		form.AddNonFieldError("Invalid filters.")
+		return form, models.AuditFilter{}
+	}
+
+	if form.Page == 0 {
+		form.Page = 1
+	}
+
+	form.CheckField(form.Action == "" || validator.PermittedValue(form.Action, models.AuditActions...), "action", "Unknown action.")
+	form.CheckField(form.TargetID >= 0, "target", "Target ID must be positive.")
+	form.CheckField(form.Page > 0, "page", "Page must be positive.")
+
+	filter := models.AuditFilter{
+		Action:   form.Action,
+		TargetID: form.TargetID,
+		Page:     form.Page,
+		PageSize: auditPageSize,
+	}
+
+	if form.From != "" {
+		filter.From, err = time.Parse(time.DateOnly, form.From)
+		form.CheckField(err == nil, "from", "Dates must be in YYYY-MM-DD format.")
+	}
+	if form.To != "" {
+		filter.To, err = time.Parse(time.DateOnly, form.To)
+		form.CheckField(err == nil, "to", "Dates must be in YYYY-MM-DD format.")
+		// Include events from the whole of the final day.
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+
+	return form, filter
+}
+
+// query returns the form's filters as URL query parameters, for building
+// links to other pages or to the export.
+func (form auditFilterForm) query() url.Values {
+	q := url.Values{}
+	if form.Action != "" {
+		q.Set("action", form.Action)
+	}
+	if form.TargetID != 0 {
+		q.Set("target", strconv.Itoa(form.TargetID))
+	}
+	if form.From != "" {
+		q.Set("from", form.From)
+	}
+	if form.To != "" {
+		q.Set("to", form.To)
+	}
+	return q
+}
+
+// ExportURL returns the URL to export the filtered audit log in the given
+// format. It is called from audit.tmpl.
+func (form auditFilterForm) ExportURL(format string) string {
+	q := form.query()
+	q.Set("format", format)
+	return "/audit/export?" + q.Encode()
+}
+
+// auditLogView handles GET /audit by displaying a page of the audit log, filtered
+// by the query string parameters described in auditFilterForm.
+func (app *application) auditLogView(w http.ResponseWriter, r *http.Request) {
+	form, filter := app.readAuditFilter(r)
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.AuditActions = models.AuditActions
+
+	if !form.Valid() {
+		app.render(w, r, http.StatusUnprocessableEntity, "audit.tmpl", data)
+		return
+	}
+
+	events, metadata, err := app.auditLog.GetAll(r.Context(), filter)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.AuditEvents = events
+	data.Pagination = pagination{Metadata: metadata}
+
+	q := form.query()
+	if metadata.CurrentPage > 1 {
+		q.Set("page", strconv.Itoa(metadata.CurrentPage-1))
+		data.Pagination.PrevURL = "/audit?" + q.Encode()
+	}
+	if metadata.CurrentPage < metadata.LastPage {
+		q.Set("page", strconv.Itoa(metadata.CurrentPage+1))
+		data.Pagination.NextURL = "/audit?" + q.Encode()
+	}
+
+	app.render(w, r, http.StatusOK, "audit.tmpl", data)
+}
+
+// auditLogExport handles GET /audit/export by sending all audit events that
+// match the filters as a CSV or JSON download, depending on the format query
+// parameter. The export itself is recorded in the audit log.
+func (app *application) auditLogExport(w http.ResponseWriter, r *http.Request) {
+	form, filter := app.readAuditFilter(r)
+	format := r.URL.Query().Get("format")
+	if !form.Valid() || !validator.PermittedValue(format, "csv", "json") {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	app.audit(r, models.AuditLogExported, "audit", 0, nil, map[string]any{"format": format, "filters": form.query()})
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	// Headers and part of the body may have been sent by the time an error
+	// occurs, so errors are logged rather than sent as a response.
+	var err error
+	switch format {
+	case "csv":
+		err = app.exportAuditCSV(w, r, filter)
+	case "json":
+		err = app.exportAuditJSON(w, r, filter)
+	}
+	if err != nil {
+		app.requestLogger(r).Error("audit export failed", "error", err.Error())
+	}
+}
+
+// exportAuditCSV writes the audit events matching filter to w as CSV, with a
+// header row.
+func (app *application) exportAuditCSV(w http.ResponseWriter, r *http.Request, filter models.AuditFilter) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "created", "actor_id", "action", "target_type", "target_id", "ip", "request_id", "before", "after"})
+
+	err := app.auditLog.Export(r.Context(), filter, func(e models.AuditEvent) error {
+		actorID := ""
+		if e.ActorID != 0 {
+			actorID = strconv.Itoa(e.ActorID)
+		}
+		return cw.Write([]string{
+			strconv.Itoa(e.ID),
+			e.Created.UTC().Format(time.RFC3339),
+			actorID,
+			e.Action,
+			e.TargetType,
+			strconv.Itoa(e.TargetID),
+			e.IP,
+			e.RequestID,
+			string(e.Before),
+			string(e.After),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportAuditJSON writes the audit events matching filter to w as a JSON
+// array. Events are encoded one at a time as they are read from the database.
+func (app *application) exportAuditJSON(w http.ResponseWriter, r *http.Request, filter models.AuditFilter) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	sep := "[\n"
+	err := app.auditLog.Export(r.Context(), filter, func(e models.AuditEvent) error {
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		sep = ","
+		return enc.Encode(e)
+	})
+	if err != nil {
+		return err
+	}
+
+	// An empty log is exported as an empty array.
+	if sep == "[\n" {
+		_, err = io.WriteString(w, "[\n]\n")
+		return err
+	}
+	_, err = io.WriteString(w, "]\n")
+	return err
+}
+
+//
+// Webhook handlers
+//
+
+// webhookDeliveryLimit is the number of recent deliveries displayed on a
+// webhook's page.
+const webhookDeliveryLimit = 50
+
+// webhookFormFields struct contains the form fields for the form to create a
+// webhook subscription.
+type webhookFormFields struct {
+	URL                 string   `form:"url"`
+	EventTypes          []string `form:"event_types"`
+	validator.Validator `form:"-"`
+}
+
+// webhookList handles GET /webhooks by displaying all webhook subscriptions,
+// along with a form to create a new one.
+func (app *application) webhookList(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := app.webhooks.GetAll(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhooks = webhooks
+	data.EventTypes = models.EventTypes
+	data.Form = webhookFormFields{EventTypes: models.EventTypes}
+
+	app.render(w, r, http.StatusOK, "webhooks.tmpl", data)
+}
+
+// webhookCreatePost handles POST /webhooks/create by creating a webhook
+// subscription, with a randomly generated signing secret, and redirecting to
+// its page, where the secret is shown once. If the form is invalid, the list
+// page is rendered again with the errors.
+func (app *application) webhookCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form webhookFormFields
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.URL), "url", "This field can't be blank.")
+	form.CheckField(validator.MaxChars(form.URL, 2000), "url", "This can't contain more than 2000 characters.")
+	form.CheckField(validator.HTTPURL(form.URL), "url", "This must be an http or https URL.")
+	if u, err := url.Parse(form.URL); err == nil {
+		form.CheckField(webhooks.AllowedHost(u.Hostname()), "url", "This must not be a local or private network address.")
+	}
+	form.CheckField(len(form.EventTypes) > 0, "event_types", "Select at least one event.")
+	for _, eventType := range form.EventTypes {
+		form.CheckField(validator.PermittedValue(eventType, models.EventTypes...), "event_types", "Unknown event.")
+	}
+
+	if !form.Valid() {
+		webhooks, err := app.webhooks.GetAll(r.Context())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data := app.newTemplateData(r)
+		data.Webhooks = webhooks
+		data.EventTypes = models.EventTypes
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "webhooks.tmpl", data)
+		return
+	}
+
+	secret := newWebhookSecret()
+	id, err := app.webhooks.Insert(r.Context(), form.URL, secret, form.EventTypes)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The secret is deliberately left out of the audit log.
+	app.audit(r, models.AuditWebhookCreated, "webhook", id, nil,
+		map[string]any{"url": form.URL, "event_types": form.EventTypes})
+
+	app.sessionManager.Put(r.Context(), string(createdWebhookSecret), secret)
+	app.sessionManager.Put(r.Context(), string(flash), "Webhook created! Copy the secret below now, it won't be shown again.")
+
+	http.Redirect(w, r, fmt.Sprintf("/webhooks/view/%d", id), http.StatusSeeOther)
+}
+
+// webhookView handles GET /webhooks/view/:id by displaying the webhook
+// subscription and a log of its most recent deliveries. Its signing secret is
+// only shown straight after it is created, and is masked otherwise.
+func (app *application) webhookView(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	webhook, err := app.webhooks.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	deliveries, err := app.webhooks.GetDeliveries(r.Context(), id, webhookDeliveryLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhook = webhook
+	data.Deliveries = deliveries
+	data.WebhookSecret = maskSecret(webhook.Secret)
+	if app.sessionManager.PopString(r.Context(), string(createdWebhookSecret)) == webhook.Secret {
+		data.WebhookSecret = webhook.Secret
+	}
+
+	app.render(w, r, http.StatusOK, "webhook.tmpl", data)
+}
+
+// webhookDeletePost handles POST /webhooks/delete/:id by deleting the webhook
+// subscription. Any pending deliveries are deleted with it.
+func (app *application) webhookDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	webhook, err := app.webhooks.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.webhooks.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditWebhookDeleted, "webhook", id,
+		map[string]any{"url": webhook.URL, "event_types": webhook.EventTypes}, nil)
+
+	app.sessionManager.Put(r.Context(), string(flash), "Webhook deleted.")
+
+	http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+}
+
+//
+// Company handlers
+//
+
+// freeMailDomains are email domains shared by many unrelated people, which
+// aren't used to suggest or create companies.
+var freeMailDomains = []string{
+	"aol.com",
+	"gmail.com",
+	"googlemail.com",
+	"hotmail.com",
+	"icloud.com",
+	"live.com",
+	"mail.com",
+	"me.com",
+	"msn.com",
+	"outlook.com",
+	"proton.me",
+	"protonmail.com",
+	"yahoo.com",
+	"zoho.com",
+}
+
+// companyDomain returns the lowercased domain of the email address, or an
+// empty string if it isn't a valid address or its domain is in
+// freeMailDomains.
+func companyDomain(email string) string {
+	email = strings.TrimSpace(email)
+	if !validator.Matches(email, validator.EmailRX) {
+		return ""
+	}
+
+	domain := strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+	if slices.Contains(freeMailDomains, domain) {
+		return ""
+	}
+	return domain
+}
+
+// companyFormFields struct contains the form fields for the form to edit a
+// company on its page.
+type companyFormFields struct {
+	Name                string `form:"name"`
+	Domain              string `form:"domain"`
+	Notes               string `form:"notes"`
+	validator.Validator `form:"-"`
+}
+
+// companySuggest handles GET /contacts/suggest-company, which is sent by HTMX
+// when the email field of the create or edit form changes. The company field
+// is rendered again, filled in with the company whose domain matches the
+// email address, unless it already has a value.
+func (app *application) companySuggest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	field := formField{Name: "company", Label: contactFormFieldLabels["company"], Value: query.Get("company")}
+
+	if strings.TrimSpace(field.Value) == "" {
+		company, err := app.companies.GetByDomain(r.Context(), companyDomain(query.Get("email")))
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+		field.Value = company.Name
+	}
+
+	app.renderFragment(w, r, http.StatusOK, "create.tmpl", "company-field", field)
+}
+
+// companyOptions handles GET /contacts/company-options, which is sent by HTMX
+// as the company field of the create or edit form is typed. The field's list
+// of suggestions is rendered again with the companies matching its value.
+func (app *application) companyOptions(w http.ResponseWriter, r *http.Request) {
+	companies, err := app.companies.Search(r.Context(), r.URL.Query().Get("company"), companyOptionsLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.renderFragment(w, r, http.StatusOK, "create.tmpl", "company-options", companies)
+}
+
+// companyList handles GET /companies by displaying all companies, with the
+// number of contacts at each.
+func (app *application) companyList(w http.ResponseWriter, r *http.Request) {
+	companies, err := app.companies.GetAll(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Companies = companies
+
+	app.render(w, r, http.StatusOK, "companies.tmpl", data)
+}
+
+// companyView handles GET /companies/:id by displaying the company, a form to
+// edit it, and everyone at the company, grouped by department.
+func (app *application) companyView(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	company, err := app.companies.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	form := companyFormFields{Name: company.Name, Domain: company.Domain, Notes: company.Notes}
+	app.renderCompany(w, r, http.StatusOK, company, form)
+}
+
+// companyEditPost handles POST /companies/:id by updating the company and
+// redirecting to its page. If the form is invalid, or the name or domain
+// belongs to another company, the page is rendered again with the errors.
+func (app *application) companyEditPost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIdParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	var form companyFormFields
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	before, err := app.companies.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	form.Name = strings.TrimSpace(form.Name)
+	form.Domain = strings.ToLower(strings.TrimSpace(form.Domain))
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field can't be blank.")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This can't contain more than 100 characters.")
+	if form.Domain != "" {
+		form.CheckField(validator.Matches(form.Domain, validator.DomainRX), "domain", "This must be a domain, such as example.com.")
+	}
+	form.CheckField(validator.MaxChars(form.Notes, 5000), "notes", "This can't contain more than 5000 characters.")
+
+	if !form.Valid() {
+		app.renderCompany(w, r, http.StatusUnprocessableEntity, before, form)
+		return
+	}
+
+	company := models.Company{ID: id, Name: form.Name, Domain: form.Domain, Notes: form.Notes}
+	err = app.companies.Update(r.Context(), company)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateCompany):
+			form.AddNonFieldError("Another company already has this name or domain.")
+			app.renderCompany(w, r, http.StatusUnprocessableEntity, before, form)
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.AuditCompanyUpdated, "company", id, companySummary(before), companySummary(company))
+
+	app.sessionManager.Put(r.Context(), string(flash), "Company successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/companies/%d", id), http.StatusSeeOther)
+}
+
+// renderCompany renders the company's page with the given edit form, along
+// with the company's contacts.
+func (app *application) renderCompany(w http.ResponseWriter, r *http.Request, status int, company models.Company, form companyFormFields) {
+	contacts, err := app.contacts.GetByCompany(r.Context(), company.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Company = company
+	data.Contacts = contacts
+	data.Form = form
+
+	app.render(w, r, status, "company.tmpl", data)
+}
+
+// companySummary returns a summary of the company's fields, for the audit log.
+func companySummary(c models.Company) map[string]string {
+	return map[string]string{
+		"name":   c.Name,
+		"domain": c.Domain,
+		"notes":  c.Notes,
+	}
 }
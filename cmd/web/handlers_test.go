@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+func TestContactConflicts(t *testing.T) {
+	base := models.Contact{First: "Jane", Last: "Doe", Phone: "555-123-4567", Email: "jane@example.com", Company: "Acme"}
+	yours := models.Contact{First: "Janet", Last: "Doe", Phone: "555-123-4567", Email: "janet@example.com", Company: "Acme", Department: "Sales"}
+	current := models.Contact{First: "Jane", Last: "Smith", Phone: "555-123-4567", Email: "jd@example.com", Company: "Globex",
+		Birthday: time.Date(1990, 3, 5, 0, 0, 0, 0, time.UTC)}
+
+	testCases := []struct {
+		field       string
+		conflicting bool
+		keepYours   bool
+	}{
+		// Only you changed the first name, so your value is kept by default.
+		{"first", true, true},
+		// Only the other user changed the last name, so theirs is kept.
+		{"last", true, false},
+		// Nobody changed the phone number, so there is nothing to choose.
+		{"phone", false, false},
+		// You both changed the email, so yours is kept by default.
+		{"email", true, true},
+		// Only the other user changed the company.
+		{"company", true, false},
+		{"title", false, false},
+		// Only you set the department.
+		{"department", true, true},
+		// Only the other user set the birthday.
+		{"birthday", true, false},
+	}
+
+	conflicts := contactConflicts(base, yours, current)
+	assert.Equal(t, len(conflicts), len(testCases))
+
+	for i, tc := range testCases {
+		t.Run(tc.field, func(t *testing.T) {
+			c := conflicts[i]
+			assert.Equal(t, c.Name, tc.field)
+			assert.Equal(t, c.Conflicting(), tc.conflicting)
+			assert.Equal(t, c.KeepYours(), tc.keepYours)
+		})
+	}
+}
+
+func TestBulkFormValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		form  bulkFormFields
+		valid bool
+	}{
+		{"Delete", bulkFormFields{Action: bulkDelete}, true},
+		{"Export", bulkFormFields{Action: bulkExportCSV}, true},
+		{"Unknown Action", bulkFormFields{Action: "truncate"}, false},
+		{"Tag", bulkFormFields{Action: bulkTag, Tag: " customer "}, true},
+		{"Blank Tag", bulkFormFields{Action: bulkTag, Tag: "  "}, false},
+		{"Set Phone", bulkFormFields{Action: bulkSetField, Field: "phone", Value: "555-123-4567"}, true},
+		{"Invalid Phone", bulkFormFields{Action: bulkSetField, Field: "phone", Value: "call me"}, false},
+		{"Field Not Settable", bulkFormFields{Action: bulkSetField, Field: "email", Value: "a@example.com"}, false},
+		{"Blank Value", bulkFormFields{Action: bulkSetField, Field: "last", Value: ""}, false},
+		{"Set Department", bulkFormFields{Action: bulkSetField, Field: "department", Value: "Sales"}, true},
+		{"Set Company", bulkFormFields{Action: bulkSetField, Field: "company", Value: "Acme"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.form.validate()
+			assert.Equal(t, tc.form.Valid(), tc.valid)
+		})
+	}
+}
+
+func TestBulkFormDedupe(t *testing.T) {
+	form := bulkFormFields{IDs: []int{3, 1, 3, 2, 1}, Versions: []int{30, 10, 31, 20, 11}}
+	form.dedupe()
+	assert.Equal(t, form.IDs, []int{3, 1, 2})
+	assert.Equal(t, form.Versions, []int{30, 10, 20})
+
+	// The home page's form has no versions.
+	form = bulkFormFields{IDs: []int{5, 5}}
+	form.dedupe()
+	assert.Equal(t, form.IDs, []int{5})
+	assert.Equal(t, len(form.Versions), 0)
+}
+
+func TestBulkFormCheckTagLimit(t *testing.T) {
+	full := make([]string, models.MaxTags)
+	for i := range full {
+		full[i] = fmt.Sprintf("tag%d", i)
+	}
+
+	testCases := []struct {
+		name     string
+		tag      string
+		contacts []models.Contact
+		valid    bool
+	}{
+		{"Room For Tag", "customer", []models.Contact{{Tags: []string{"lead"}}}, true},
+		{"Full", "customer", []models.Contact{{Tags: []string{"lead"}}, {Tags: full}}, false},
+		{"Full With Tag", "tag0", []models.Contact{{Tags: full}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			form := bulkFormFields{Action: bulkTag, Tag: tc.tag}
+			form.checkTagLimit(tc.contacts)
+			assert.Equal(t, form.Valid(), tc.valid)
+		})
+	}
+}
+
+func TestCompanyDomain(t *testing.T) {
+	testCases := []struct {
+		name     string
+		email    string
+		expected string
+	}{
+		{"Company Address", "jane@acme.com", "acme.com"},
+		{"Uppercase", "Jane@Mail.ACME.com", "mail.acme.com"},
+		{"Free Mail", "jane@gmail.com", ""},
+		{"Invalid Address", "jane at acme.com", ""},
+		{"Empty", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, companyDomain(tc.email), tc.expected)
+		})
+	}
+}
+
+func TestContactFormValidateBirthday(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(birthdayLayout)
+
+	testCases := []struct {
+		name     string
+		birthday string
+		valid    bool
+	}{
+		{"Blank", "", true},
+		{"Date", "1990-03-05", true},
+		{"Leap Day", "1992-02-29", true},
+		{"Invalid Date", "1991-02-29", false},
+		{"Wrong Format", "05/03/1990", false},
+		{"Future", tomorrow, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			form := contactFormFields{First: "Jane", Last: "Doe", Phone: "555-123-4567", Email: "jane@example.com", Birthday: tc.birthday}
+			form.validate()
+			assert.Equal(t, form.Valid(), tc.valid)
+		})
+	}
+}
+
+func TestNoteFormValidate(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(occurredLayout)
+
+	// The current time east of UTC is in the future in UTC.
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nowInTokyo := time.Now().In(tokyo).Format(occurredLayout)
+	nowInUTC, _ := time.ParseInLocation(occurredLayout, nowInTokyo, tokyo)
+
+	testCases := []struct {
+		name     string
+		form     noteFormFields
+		valid    bool
+		occurred time.Time
+	}{
+		{"Note", noteFormFields{Kind: models.NoteKindNote, Body: "Likes golf"}, true, time.Time{}},
+		{"Call With Time", noteFormFields{Kind: models.NoteKindCall, Body: "Left a voicemail", Occurred: "2024-03-05T14:30"},
+			true, time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)},
+		{"Call In Time Zone", noteFormFields{Kind: models.NoteKindCall, Body: "Left a voicemail", Occurred: "2024-03-05T14:30", Timezone: "Asia/Tokyo"},
+			true, time.Date(2024, 3, 5, 5, 30, 0, 0, time.UTC)},
+		{"Unknown Time Zone", noteFormFields{Kind: models.NoteKindCall, Body: "Left a voicemail", Occurred: "2024-03-05T14:30", Timezone: "Mars/Olympus_Mons"},
+			false, time.Time{}},
+		{"Unknown Kind", noteFormFields{Kind: "fax", Body: "Sent a fax"}, false, time.Time{}},
+		{"Blank Body", noteFormFields{Kind: models.NoteKindNote, Body: " "}, false, time.Time{}},
+		{"Invalid Time", noteFormFields{Kind: models.NoteKindMeeting, Body: "Lunch", Occurred: "yesterday"}, false, time.Time{}},
+		{"Future Time", noteFormFields{Kind: models.NoteKindMeeting, Body: "Lunch", Occurred: future}, false, time.Time{}},
+		{"Now In Time Zone", noteFormFields{Kind: models.NoteKindCall, Body: "Called", Occurred: nowInTokyo, Timezone: "Asia/Tokyo"},
+			true, nowInUTC.UTC()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			occurred := tc.form.validate()
+			assert.Equal(t, tc.form.Valid(), tc.valid)
+			if tc.valid {
+				assert.Equal(t, occurred, tc.occurred)
+			}
+		})
+	}
+}
+
+func TestReminderFormValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		form  reminderFormFields
+		valid bool
+		due   time.Time
+	}{
+		{"One Off", reminderFormFields{Due: "2024-03-12T09:00", Note: "Call back"},
+			true, time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)},
+		{"Recurring", reminderFormFields{Due: "2024-03-12T09:00", Recurrence: "FREQ=WEEKLY;INTERVAL=2"},
+			true, time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)},
+		{"Time Zone", reminderFormFields{Due: "2024-03-12T09:00", Timezone: "America/New_York"},
+			true, time.Date(2024, 3, 12, 13, 0, 0, 0, time.UTC)},
+		{"Unknown Time Zone", reminderFormFields{Due: "2024-03-12T09:00", Timezone: "Mars/Olympus_Mons"}, false, time.Time{}},
+		{"Blank Due", reminderFormFields{Note: "Call back"}, false, time.Time{}},
+		{"Invalid Recurrence", reminderFormFields{Due: "2024-03-12T09:00", Recurrence: "FREQ=HOURLY"}, false, time.Time{}},
+		{"Long Note", reminderFormFields{Due: "2024-03-12T09:00", Note: strings.Repeat("a", 501)}, false, time.Time{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			due := tc.form.validate()
+			assert.Equal(t, tc.form.Valid(), tc.valid)
+			if tc.valid {
+				assert.Equal(t, due, tc.due)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvnloughead/contacts-app/migrations"
+)
+
+// healthCheckTimeout is the maximum time each readiness check may take.
+const healthCheckTimeout = 2 * time.Second
+
+// checkResult is the outcome of a single readiness check, as reported in the
+// JSON response of GET /readyz.
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthz handles GET /healthz. It is a liveness check: if the application is
+// able to respond at all, it is alive, so it always responds with 200 OK.
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz handles GET /readyz. It is a readiness check, reporting whether the
+// application is able to serve traffic. Each of the application's dependencies
+// is checked, and a 503 Service Unavailable response is sent if any of them
+// fail, or if the application is shutting down.
+//
+// The response body contains the status of each check, for example:
+//
+//	{
+//	  "status": "unavailable",
+//	  "checks": {
+//	    "database": {"status": "ok"},
+//	    "migrations": {"status": "error", "error": "2 pending migrations"},
+//	    "sessions": {"status": "ok"},
+//	    "templates": {"status": "ok"}
+//	  }
+//	}
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]error{
+		"database":   app.health.Ping(ctx),
+		"sessions":   app.checkSessionStore(ctx),
+		"templates":  app.checkTemplateCache(),
+		"migrations": app.checkMigrations(ctx),
+	}
+
+	status := http.StatusOK
+	body := struct {
+		Status string                 `json:"status"`
+		Checks map[string]checkResult `json:"checks"`
+	}{Status: "ready", Checks: map[string]checkResult{}}
+
+	for name, err := range checks {
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			body.Status = "unavailable"
+			body.Checks[name] = checkResult{Status: "error", Error: err.Error()}
+			continue
+		}
+		body.Checks[name] = checkResult{Status: "ok"}
+	}
+
+	// While shutting down, report the instance as unavailable even if all
+	// checks pass, so that load balancers stop routing traffic to it.
+	if app.shuttingDown.Load() {
+		status = http.StatusServiceUnavailable
+		body.Status = "shutting down"
+	}
+
+	app.writeJSON(w, r, status, body)
+}
+
+// checkSessionStore verifies that the session store can be queried. The
+// postgresstore methods don't accept a context, so the lookup is run in a
+// goroutine and abandoned if ctx is done first.
+func (app *application) checkSessionStore(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		_, _, err := app.sessionManager.Store.Find("readiness-check")
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// checkTemplateCache verifies that the template cache has been populated.
+func (app *application) checkTemplateCache() error {
+	if len(app.templateCache) == 0 {
+		return errors.New("template cache is empty")
+	}
+	return nil
+}
+
+// checkMigrations verifies that all of the migrations embedded in the binary
+// have been applied, and that the last one didn't fail part way through.
+func (app *application) checkMigrations(ctx context.Context) error {
+	latest, err := latestMigrationVersion(migrations.Files)
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := app.health.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case dirty:
+		return fmt.Errorf("migration %d is dirty", version)
+	case version < latest:
+		return fmt.Errorf("database is at version %d, latest migration is %d", version, latest)
+	}
+
+	return nil
+}
+
+// latestMigrationVersion returns the highest version number of the migration
+// files in fsys. Migration files are named like 000001_create_table.up.sql.
+func latestMigrationVersion(fsys fs.FS) (int, error) {
+	files, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(path.Base(file), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration filename %q", file)
+		}
+		latest = max(latest, version)
+	}
+
+	return latest, nil
+}
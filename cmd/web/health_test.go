@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-playground/assert/v2"
+)
+
+// TestLatestMigrationVersion tests finding the highest migration version from
+// migration filenames.
+func TestLatestMigrationVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         []string
+		expected      int
+		expectedError bool
+	}{
+		{"No Migrations", []string{}, 0, false},
+		{
+			name:     "Up and Down Migrations",
+			files:    []string{"000001_a.up.sql", "000001_a.down.sql", "000002_b.up.sql", "000002_b.down.sql"},
+			expected: 2,
+		},
+		{
+			name:     "Ignores Down Only",
+			files:    []string{"000001_a.up.sql", "000003_c.down.sql"},
+			expected: 1,
+		},
+		{
+			name:          "Invalid Filename",
+			files:         []string{"000001_a.up.sql", "first.up.sql"},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, f := range tt.files {
+				fsys[f] = &fstest.MapFile{}
+			}
+
+			version, err := latestMigrationVersion(fsys)
+			assert.Equal(t, err != nil, tt.expectedError)
+			assert.Equal(t, version, tt.expected)
+		})
+	}
+}
@@ -3,21 +3,39 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"runtime/debug"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/form/v4"
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
 )
 
+// statusClientClosedRequest is the nonstandard status code, borrowed from
+// nginx, that is recorded for requests abandoned by the client.
+const statusClientClosedRequest = 499
+
 /*
-Writes an Error level log entry, including the request method and uri, and
-returns a 500 Internal Server Error.
+Writes an Error level log entry with the request-scoped logger, which includes
+the request ID, method and uri, and returns a 500 Internal Server Error.
+
+If the error is due to a database query timing out, a 503 Service Unavailable
+response is sent instead (see serviceUnavailable). If it is due to the client
+disconnecting, which cancels the request context, there is no one to send a
+response to, so the error is logged at Info level and only the status code
+499 is recorded for the logging and metrics middleware.
 
 If run in debug mode, the full stack trace is sent to the client.
 */
@@ -26,14 +44,20 @@ func (app *application) serverError(
 	r *http.Request,
 	err error,
 ) {
-	var (
-		method = r.Method
-		uri    = r.URL.RequestURI()
-		trace  = string(debug.Stack())
-	)
+	if errors.Is(err, context.DeadlineExceeded) {
+		app.serviceUnavailable(w, r, err)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		app.requestLogger(r).Info("client disconnected", "error", err.Error())
+		w.WriteHeader(statusClientClosedRequest)
+		return
+	}
+
+	trace := string(debug.Stack())
 
 	// Log error with stack trace.
-	app.logger.Error(err.Error(), "method", method, "uri", uri)
+	app.requestLogger(r).Error(err.Error())
 
 	if app.config.Debug.value {
 		body := fmt.Sprintf("%s\n%s", err, trace)
@@ -46,6 +70,17 @@ func (app *application) serverError(
 
 }
 
+// serviceUnavailable logs a warning and sends a 503 Service Unavailable
+// response, with a Retry-After header. It is used when a request fails
+// because a query exceeded its deadline, which usually indicates that the
+// database is overloaded rather than that there is a bug.
+func (app *application) serviceUnavailable(w http.ResponseWriter, r *http.Request, err error) {
+	app.requestLogger(r).Warn(err.Error())
+
+	w.Header().Set("Retry-After", "5")
+	app.clientError(w, http.StatusServiceUnavailable)
+}
+
 /*
 Sends a status code and corresponding description to the user. Uses
 http.StatusText to generate the standard description.
@@ -65,6 +100,23 @@ func (app *application) notFound(w http.ResponseWriter) {
 // Retrieves appropriate template from the cache, based on the page string.
 // If no entry exists in the cache, a 500 server error is returned.
 func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
+	app.renderTemplate(w, r, status, page, "base", data)
+}
+
+// renderFragment renders a single named template from the page's template
+// set, rather than the whole page. It is used to respond to HTMX requests
+// (see isHTMX) with just the part of the page that changed. Since each page's
+// set includes the partials, fragments defined in partials can be rendered
+// with any page.
+//
+// Fragments may take any data, not only templateData.
+func (app *application) renderFragment(w http.ResponseWriter, r *http.Request, status int, page string, fragment string, data any) {
+	app.renderTemplate(w, r, status, page, fragment, data)
+}
+
+// renderTemplate executes the template called name from the page's template
+// set, and sends the result with the given status code.
+func (app *application) renderTemplate(w http.ResponseWriter, r *http.Request, status int, page string, name string, data any) {
 	ts, ok := app.templateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
@@ -75,7 +127,9 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 	// Write template to a buffer, instead of immediately to the ResponseWriter.
 	// If there's an error, return a server error instead of a 200 response.
 	buf := new(bytes.Buffer)
-	err := ts.ExecuteTemplate(buf, "base", data)
+	start := time.Now()
+	err := ts.ExecuteTemplate(buf, name, data)
+	app.metrics.renderDuration.WithLabelValues(page).Observe(time.Since(start).Seconds())
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -87,6 +141,34 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 	buf.WriteTo(w) // write contents of buffer to http.ResponseWriter
 }
 
+// isHTMX returns true if the request was made by HTMX, rather than by a
+// regular link or form submission.
+func isHTMX(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
+
+// setHXTrigger sets the HX-Trigger response header, which causes HTMX to
+// trigger a showMessage event on the page. The message is displayed like a
+// flash message by main.js.
+func setHXTrigger(w http.ResponseWriter, message string) {
+	trigger, _ := json.Marshal(map[string]string{"showMessage": message})
+	w.Header().Set("HX-Trigger", string(trigger))
+}
+
+// writeJSON encodes data as JSON and sends it with the given status code. If
+// data can't be encoded, a server error is sent instead.
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
 // Initialize templateData struct with the CurrentYear.
 func (app *application) newTemplateData(r *http.Request) templateData {
 	return templateData{
@@ -134,6 +216,47 @@ func (app *application) readIdParam(r *http.Request) (int, error) {
 	return id, nil
 }
 
+// absoluteURL returns an absolute URL for the given path on this server, such
+// as https://example.com/s/abc, for links that are used outside of the app.
+// The scheme and host are taken from the configured base URL, since the
+// request's Host header is set by the client, and behind a TLS terminating
+// proxy the request doesn't say whether the client used https.
+func (app *application) absoluteURL(path string) string {
+	return app.config.BaseURL + path
+}
+
+// audit records an action in the audit log, along with the actor, IP and
+// request ID of the request. before and after are JSON encoded summaries of
+// the target before and after the action, and may be nil.
+//
+// The action has already happened by the time audit is called, so a failure
+// to record it is logged rather than returned to the user.
+func (app *application) audit(r *http.Request, action, targetType string, targetID int, before, after any) {
+	event := models.AuditEvent{
+		ActorID:    app.sessionManager.GetInt(r.Context(), string(authenticatedUserID)),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         clientIP(r, app.config.Limiter.TrustedProxies),
+		RequestID:  requestIDFromContext(r.Context()),
+	}
+
+	var err error
+	if before != nil {
+		event.Before, err = json.Marshal(before)
+	}
+	if err == nil && after != nil {
+		event.After, err = json.Marshal(after)
+	}
+	if err == nil {
+		err = app.auditLog.Insert(r.Context(), event)
+	}
+
+	if err != nil {
+		app.requestLogger(r).Error("failed to record audit event", "action", action, "error", err.Error())
+	}
+}
+
 // Returns true if the request is coming from an authenticated user. Authentication is determined by the presence and value of an isAuthenticatedContextKey in the request context.
 //
 // False will be returned if the key doesn't exist, if it's value isn't boolean, or if its value is false.
@@ -144,3 +267,56 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// requestLogger is a wrapper around the request-scoped *slog.Logger. It is
+// stored in the request context as a pointer so that middleware that runs
+// after logRequest, such as logUserID, can add attributes that will also be
+// included in logRequest's completion line.
+type requestLogger struct {
+	*slog.Logger
+}
+
+// requestLogger returns the request-scoped logger created by the logRequest
+// middleware. If there isn't one, app.logger is returned.
+func (app *application) requestLogger(r *http.Request) *slog.Logger {
+	logger, ok := r.Context().Value(loggerContextKey).(*requestLogger)
+	if !ok {
+		return app.logger
+	}
+	return logger.Logger
+}
+
+// requestIDFromContext returns the request ID stored in the context by the
+// requestID middleware, or an empty string if there isn't one.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// newWebhookSecret returns a random 64 character hex string to use as a
+// webhook's signing secret.
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// maskSecret returns the last four characters of a webhook's signing secret,
+// preceded by bullets, so that the secret can be recognized without being
+// shown.
+func maskSecret(secret string) string {
+	masked := strings.Repeat("•", 8)
+	if len(secret) > 4 {
+		masked += secret[len(secret)-4:]
+	}
+	return masked
+}
+
+// newRequestID returns a random 32 character hex string to use as a request
+// ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	// rand.Read never returns an error on supported platforms.
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+// TestServerError tests that serverError sends the response appropriate to
+// the kind of error.
+func TestServerError(t *testing.T) {
+	app := &application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		body   bool
+	}{
+		{"Other Error", errors.New("boom"), http.StatusInternalServerError, true},
+		{"Query Timeout", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, true},
+		{"Client Disconnected", fmt.Errorf("query: %w", context.Canceled), statusClientClosedRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			rr := httptest.NewRecorder()
+
+			app.serverError(rr, r, tt.err)
+
+			assert.Equal(t, rr.Code, tt.status)
+			assert.Equal(t, rr.Body.Len() > 0, tt.body)
+		})
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		want   string
+	}{
+		{"Secret", "0123456789abcdef", "••••••••cdef"},
+		{"Short Secret", "abcd", "••••••••"},
+		{"Empty", "", "••••••••"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, maskSecret(tt.secret), tt.want)
+		})
+	}
+}
@@ -8,12 +8,21 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
 
+	// Embed the time zone database, since note and reminder times are read in
+	// the browser's time zone, and the server may not have one installed.
+	_ "time/tzdata"
+
 	"github.com/alexedwards/scs/postgresstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
+	"github.com/kvnloughead/contacts-app/internal/events"
+	"github.com/kvnloughead/contacts-app/internal/mailer"
 	"github.com/kvnloughead/contacts-app/internal/models"
+	"github.com/kvnloughead/contacts-app/internal/reminders"
+	"github.com/kvnloughead/contacts-app/internal/webhooks"
 
 	// Aliasing with a blank identifier because the driver isn't used explicitly.
 	_ "github.com/lib/pq"
@@ -24,9 +33,26 @@ type application struct {
 	config         Config
 	logger         *slog.Logger
 	contacts       models.ContactModelInterface
+	health         models.HealthModelInterface
+	shares         models.ShareModelInterface
+	auditLog       models.AuditModelInterface
+	webhooks       models.WebhookModelInterface
+	companies      models.CompanyModelInterface
+	relations      models.RelationModelInterface
+	notes          models.NoteModelInterface
+	reminders      models.ReminderModelInterface
+	notifications  models.NotificationModelInterface
+	calendar       models.CalendarModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	metrics        *metrics
+	mailer         mailer.Mailer
+	events         *events.Broker
+	limiter        *ipRateLimiter
+
+	// Set to true when the server begins shutting down. See app.serve.
+	shuttingDown atomic.Bool
 }
 
 func main() {
@@ -62,11 +88,25 @@ func main() {
 
 	app := &application{
 		logger:         logger,
-		contacts:       &models.ContactModel{DB: db},
+		contacts:       &models.ContactModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
+		health:         &models.HealthModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
+		shares:         &models.ShareModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
+		auditLog:       &models.AuditModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
+		webhooks:       &models.WebhookModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
+		companies:      &models.CompanyModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
+		relations:      &models.RelationModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
+		notes:          &models.NoteModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
+		reminders:      &models.ReminderModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
+		notifications:  &models.NotificationModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
+		calendar:       &models.CalendarModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
 		config:         cfg,
+		metrics:        newMetrics(db, &models.SessionModel{DB: db, QueryTimeout: cfg.DB.QueryTimeout}),
+		mailer:         newMailer(cfg.SMTP, logger),
+		events:         events.NewBroker(logger),
+		limiter:        newIPRateLimiter(cfg.Limiter.RPS, cfg.Limiter.Burst),
 	}
 
 	// Initial http server with address route handler.
@@ -81,13 +121,98 @@ func main() {
 		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError),
 	}
 
+	// End open event streams when the server shuts down, since Shutdown waits
+	// for all requests to complete.
+	srv.RegisterOnShutdown(app.events.Close)
+
+	// If a separate metrics port is configured, serve /metrics on it in the
+	// background. Otherwise it is served by app.routes.
+	if cfg.MetricsPort != 0 {
+		go app.serveMetrics()
+	}
+
+	// Run background tasks until the server has shut down.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+
+	// Remove idle clients from the rate limiter.
+	go app.limiter.cleanup(bgCtx)
+
+	// Listen for contact change events from the database, and broadcast them to
+	// clients of GET /events.
+	go func() {
+		err := app.events.Listen(bgCtx, cfg.DB.DSN, app.webhooks)
+		if err != nil {
+			logger.Error("listening for events failed", "error", err.Error())
+		}
+	}()
+
+	// Deliver webhooks.
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		worker := &webhooks.Worker{
+			Model:        app.webhooks,
+			Logger:       logger,
+			Client:       webhooks.NewClient(cfg.Webhooks.Timeout),
+			PollInterval: cfg.Webhooks.PollInterval,
+			BatchSize:    100,
+			Retention:    cfg.Webhooks.Retention,
+		}
+		worker.Run(bgCtx)
+	}()
+
+	// Fire due reminders.
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		scheduler := &reminders.Scheduler{
+			Model:        app.reminders,
+			Mailer:       app.mailer,
+			Logger:       logger,
+			Recipient:    cfg.Reminders.Email,
+			PollInterval: cfg.Reminders.PollInterval,
+			BatchSize:    100,
+
+			// Long enough for each of the mailer's attempts.
+			SendTimeout: 3*cfg.SMTP.Timeout + time.Second,
+		}
+		scheduler.Run(bgCtx)
+	}()
+
 	/* Info level log statement. Arguments after the first can either be variadic, key/value pairs, or attribute pairs created by slog.String, or a similar method. */
 	logger.Info("starting server", slog.String("port", fmt.Sprint(cfg.Port)))
 
-	// Run the server. If an error occurs, log it and exit.
-	err = srv.ListenAndServe()
-	logger.Error(err.Error())
-	os.Exit(1)
+	// Run the server until it is shut down. If an error occurs, log it and exit.
+	err = app.serve(srv)
+
+	// Stop the background tasks, and wait for any in-progress webhook
+	// deliveries to be recorded. Reminder emails in progress are abandoned.
+	stopBackground()
+	<-workerDone
+	<-schedulerDone
+
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// newMailer returns an SMTPMailer for the configured SMTP server. If no SMTP
+// host is configured, as is usual in development, a LogMailer is returned
+// instead.
+func newMailer(smtpCfg SMTPConfig, logger *slog.Logger) mailer.Mailer {
+	if smtpCfg.Host == "" {
+		return &mailer.LogMailer{Logger: logger, Dir: smtpCfg.MailDir, Sender: smtpCfg.Sender}
+	}
+
+	return &mailer.SMTPMailer{
+		Host:     smtpCfg.Host,
+		Port:     smtpCfg.Port,
+		Username: smtpCfg.Username,
+		Password: smtpCfg.Password,
+		Sender:   smtpCfg.Sender,
+		Timeout:  smtpCfg.Timeout,
+	}
 }
 
 // openDB returns an postgres sql.DB connection pool for the supplied DSN. It
@@ -115,3 +240,26 @@ func openDB(dbCfg DatabaseConfig) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// serveMetrics runs an http server that only serves /metrics, on the port
+// specified by config.MetricsPort. This allows the metrics to be exposed on an
+// admin port that isn't publicly reachable.
+func (app *application) serveMetrics() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", app.metrics.handler())
+
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.MetricsPort),
+		Handler:      mux,
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	}
+
+	app.logger.Info("starting metrics server", slog.String("port", fmt.Sprint(app.config.MetricsPort)))
+
+	err := srv.ListenAndServe()
+	app.logger.Error(err.Error())
+	os.Exit(1)
+}
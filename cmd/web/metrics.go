@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+// metrics contains the Prometheus collectors used by the application. They
+// are registered with their own registry instead of the global default one, so
+// that /metrics only exposes what is registered here.
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	renderDuration  *prometheus.HistogramVec
+}
+
+// newMetrics initializes and registers all of the application's collectors.
+// In addition to the HTTP and template metrics, which are updated by the
+// application itself, it registers collectors for the sql.DB pool stats, the
+// number of active sessions, and the Go runtime and process.
+func newMetrics(db *sql.DB, sessions models.SessionModelInterface) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests, by route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests, by route pattern, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served.",
+		}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "template_render_duration_seconds",
+			Help:    "Time taken to execute page templates, by page.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25},
+		}, []string{"page"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.renderDuration,
+		collectors.NewDBStatsCollector(db, "contacts"),
+		&sessionCollector{sessions: sessions},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// handler returns an http.Handler that serves the metrics in m.registry in
+// the Prometheus exposition format.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// sessionCollector is a prometheus.Collector that reports the number of active
+// sessions. The count is queried from the database on each scrape, so that it
+// is accurate across multiple instances of the application.
+type sessionCollector struct {
+	sessions models.SessionModelInterface
+}
+
+var sessionsActiveDesc = prometheus.NewDesc(
+	"sessions_active",
+	"Number of unexpired sessions in the session store.",
+	nil, nil,
+)
+
+func (c *sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sessionsActiveDesc
+}
+
+// Collect sends the active session count to ch. If the query fails an invalid
+// metric is sent instead, which causes the error to be reported by the scrape.
+func (c *sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	count, err := c.sessions.CountActive(context.Background())
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(sessionsActiveDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(sessionsActiveDesc, prometheus.GaugeValue, float64(count))
+}
+
+// routePattern returns the route pattern registered with the router that
+// matches the request, such as /contacts/view/:id. Labelling metrics by
+// pattern instead of by path keeps the number of label values bounded.
+//
+// The pattern is rebuilt one path segment at a time. A segment that equals the
+// value of the next parameter could still be a static part of the route, as in
+// /contacts/view/view, so it is replaced by ":" and looked up again. A colon
+// can't appear in a static segment, so the route only matches again if the
+// segment is a parameter.
+//
+// If no route matches the request, "unmatched" is returned.
+func routePattern(router *httprouter.Router, r *http.Request) string {
+	handle, params, _ := router.Lookup(r.Method, r.URL.Path)
+	if handle == nil {
+		return "unmatched"
+	}
+
+	path := r.URL.Path
+
+	// Catch-all parameter values begin with a slash and always come last.
+	var catchAll, catchAllValue string
+	if n := len(params); n > 0 && strings.HasPrefix(params[n-1].Value, "/") {
+		catchAll = "/*" + params[n-1].Key
+		catchAllValue = params[n-1].Value
+		path = strings.TrimSuffix(path, catchAllValue)
+		params = params[:n-1]
+	}
+
+	segments := strings.Split(path, "/")
+	next := 0
+	for i, segment := range segments {
+		if next == len(params) {
+			break
+		}
+		if segment != params[next].Value {
+			continue
+		}
+
+		probe := slices.Clone(segments)
+		probe[i] = ":"
+		_, probeParams, _ := router.Lookup(r.Method, strings.Join(probe, "/")+catchAllValue)
+		if len(probeParams) <= next || probeParams[next].Value != ":" {
+			continue
+		}
+
+		segments[i] = ":" + params[next].Key
+		next++
+	}
+
+	return strings.Join(segments, "/") + catchAll
+}
+
+// collectMetrics returns middleware that records the number, latency and
+// status of requests, and the number of requests in flight. The router is used
+// to look up the route pattern of each request.
+func (app *application) collectMetrics(router *httprouter.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			app.metrics.inFlight.Inc()
+			defer app.metrics.inFlight.Dec()
+
+			sw := newStatusWriter(w)
+			next.ServeHTTP(sw, r)
+
+			labels := prometheus.Labels{
+				"route":  routePattern(router, r),
+				"method": r.Method,
+				"status": strconv.Itoa(sw.status),
+			}
+			app.metrics.requests.With(labels).Inc()
+			app.metrics.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TestRoutePattern tests that request paths are mapped to the route patterns
+// they were registered with.
+func TestRoutePattern(t *testing.T) {
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	router := httprouter.New()
+	router.Handler(http.MethodGet, "/", noop)
+	router.Handler(http.MethodGet, "/contacts/view/:id", noop)
+	router.Handler(http.MethodPost, "/contacts/edit/:id", noop)
+	router.Handler(http.MethodGet, "/static/*filepath", noop)
+	router.Handler(http.MethodGet, "/s/:token/contact.vcf", noop)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected string
+	}{
+		{"Root", http.MethodGet, "/", "/"},
+		{"Named Param", http.MethodGet, "/contacts/view/12", "/contacts/view/:id"},
+		{"Named Param POST", http.MethodPost, "/contacts/edit/3", "/contacts/edit/:id"},
+		{"Param Prefix Of Segment", http.MethodGet, "/contacts/view/c", "/contacts/view/:id"},
+		{"Param Equals Segment", http.MethodGet, "/contacts/view/view", "/contacts/view/:id"},
+		{"Param Before Static", http.MethodGet, "/s/contact.vcf/contact.vcf", "/s/:token/contact.vcf"},
+		{"Catch-all Param", http.MethodGet, "/static/css/index.css", "/static/*filepath"},
+		{"Wrong Method", http.MethodPost, "/contacts/view/12", "unmatched"},
+		{"No Route", http.MethodGet, "/nope/12", "unmatched"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			assert.Equal(t, routePattern(router, r), tt.expected)
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/justinas/nosurf"
 )
@@ -25,8 +28,36 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
-// Middleware to logs each HTTP request, including the requests IP, protocol,
-// method, and URI.
+// requestIDPattern matches acceptable values for an incoming X-Request-ID
+// header. Anything else is replaced with a generated ID, so that arbitrary
+// client input doesn't end up in our logs.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// Middleware that assigns an ID to each request. If the request has a valid
+// X-Request-ID header, perhaps set by a load balancer, its value is used.
+// Otherwise a random ID is generated.
+//
+// The ID is stored in the request context, where it can be retrieved with
+// requestIDFromContext, and is echoed in the X-Request-ID response header.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Middleware to log each HTTP request. A request-scoped logger, carrying the
+// request ID, method and URI, is stored in the request context for use by
+// subsequent handlers (see app.requestLogger). When the request is received a
+// line including the IP and protocol is logged, and when it has been served a
+// completion line is logged with the response status, size and duration.
 //
 // By default, logging of static files is suppressed for the following
 // extensions: css, js, png, jpg, jpeg, ico. This behavior can be changed by
@@ -38,8 +69,18 @@ func (app *application) logRequest(next http.Handler) http.Handler {
 			protocol = r.Proto
 			method   = r.Method
 			uri      = r.URL.RequestURI()
+			start    = time.Now()
 		)
 
+		logger := &requestLogger{
+			Logger: app.logger.With(
+				"request_id", requestIDFromContext(r.Context()),
+				"method", method,
+				"uri", uri,
+			),
+		}
+		r = r.WithContext(context.WithValue(r.Context(), loggerContextKey, logger))
+
 		// Skip logging for static files, unless -verbose is true.
 		if !app.config.Verbose.value {
 			if strings.HasSuffix(r.URL.Path, ".css") ||
@@ -53,16 +94,41 @@ func (app *application) logRequest(next http.Handler) http.Handler {
 			}
 		}
 
-		app.logger.Info("received request", "ip", ip, "protocol", protocol, "method", method, "uri", uri)
+		logger.Info("received request", "ip", ip, "protocol", protocol)
+
+		sw := newStatusWriter(w)
+		next.ServeHTTP(sw, r)
+
+		logger.Info("completed request",
+			"status", sw.status,
+			"bytes", sw.bytes,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// Middleware that adds the ID of the authenticated user, if there is one, to
+// the request-scoped logger. It must come after sessionManager.LoadAndSave in
+// the chain, since the ID is stored in the session.
+func (app *application) logUserID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := app.sessionManager.GetInt(r.Context(), string(authenticatedUserID))
+		if logger, ok := r.Context().Value(loggerContextKey).(*requestLogger); ok && id != 0 {
+			logger.Logger = logger.With("user_id", id)
+		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
 /*
-Middleware to recover from panics and return a 500 server error. This should probably be the first middleware in the chain.
+Middleware to recover from panics and return a 500 server error. This should
+come directly after the logging and metrics middleware in the chain, so that
+the 500 response it sends is recorded by them.
 
-Note that this middleware will only have effect within a given go routine. So if a separate goroutine is initiated, you should include code to recover from panics inside that goroutine.
+Note that this middleware will only have effect within a given go routine. So
+if a separate goroutine is initiated, you should include code to recover from
+panics inside that goroutine.
 
 # Example
 
@@ -130,3 +196,57 @@ func noSurf(next http.Handler) http.Handler {
 	})
 	return csrfHandler
 }
+
+// limitBody returns middleware that limits request bodies to n bytes. It
+// must come before noSurf, which reads the body of multipart forms to find the
+// CSRF token. Requests that declare a longer body get a 413 response, and
+// longer bodies without a Content-Length fail to parse.
+func (app *application) limitBody(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				app.clientError(w, http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// statusWriter wraps an http.ResponseWriter, recording the status code and
+// the number of bytes written so that middleware can inspect them after the
+// handler has returned.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+// newStatusWriter returns a statusWriter wrapping w. The status defaults to
+// 200 OK, which is what net/http sends if the handler never calls WriteHeader.
+func newStatusWriter(w http.ResponseWriter) *statusWriter {
+	return &statusWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status = status
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += n
+	return n, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter. This allows
+// http.ResponseController to access methods like Flush on the original writer.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
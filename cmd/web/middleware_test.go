@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+// TestRequestID tests that valid X-Request-ID headers are accepted, that other
+// values are replaced by a generated ID, and that the ID is both stored in the
+// request context and echoed in the response.
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		accepted bool
+	}{
+		{"No Header", "", false},
+		{"Valid Header", "abc-123_DEF.456", true},
+		{"Contains Spaces", "abc 123", false},
+		{"Contains Newline", "abc\n123", false},
+		{"Too Long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctxID string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = requestIDFromContext(r.Context())
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Request-ID", tt.header)
+			}
+			rr := httptest.NewRecorder()
+
+			requestID(next).ServeHTTP(rr, r)
+
+			respID := rr.Header().Get("X-Request-ID")
+			assert.Equal(t, ctxID, respID)
+			if tt.accepted {
+				assert.Equal(t, respID, tt.header)
+			} else {
+				assert.Equal(t, len(respID), 32)
+			}
+		})
+	}
+}
+
+// TestLimitBody tests that bodies longer than the limit are rejected, whether
+// or not they declare their length.
+func TestLimitBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		status        int
+	}{
+		{"Within Limit", "abcd", 4, http.StatusOK},
+		{"Declared Too Long", "abcdefgh", 8, http.StatusRequestEntityTooLarge},
+		{"Undeclared Too Long", "abcdefgh", -1, http.StatusBadRequest},
+	}
+
+	app := &application{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := io.ReadAll(r.Body); err != nil {
+					app.clientError(w, http.StatusBadRequest)
+				}
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			r.ContentLength = tt.contentLength
+			rr := httptest.NewRecorder()
+
+			app.limitBody(4)(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.status)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ipRateLimiter maintains a token bucket rate limiter for each client IP. The
+// limiters are stored in memory, so limits apply per instance of the
+// application.
+type ipRateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*rateLimitedClient
+	rps     rate.Limit
+	burst   int
+}
+
+type rateLimitedClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newIPRateLimiter returns an ipRateLimiter allowing an average of rps
+// requests per second, with bursts of up to burst requests, for each IP. Run
+// cleanup in the background, so that the clients map doesn't grow
+// indefinitely.
+func newIPRateLimiter(rps float64, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		clients: make(map[string]*rateLimitedClient),
+		rps:     rate.Limit(rps),
+		burst:   burst,
+	}
+}
+
+// cleanup removes clients that haven't been seen for three minutes, once a
+// minute, until ctx is cancelled.
+func (l *ipRateLimiter) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.removeIdle(3 * time.Minute)
+		}
+	}
+}
+
+// removeIdle removes clients that haven't been seen for longer than idle.
+func (l *ipRateLimiter) removeIdle(idle time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ip, client := range l.clients {
+		if time.Since(client.lastSeen) > idle {
+			delete(l.clients, ip)
+		}
+	}
+}
+
+// reserve takes a token from the bucket for ip. If there is no token
+// available, no token is taken and the time until one will be is returned
+// instead. A zero duration means the request is allowed.
+func (l *ipRateLimiter) reserve(ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	client, ok := l.clients[ip]
+	if !ok {
+		client = &rateLimitedClient{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.clients[ip] = client
+	}
+	client.lastSeen = time.Now()
+
+	reservation := client.limiter.Reserve()
+	delay := reservation.Delay()
+	if delay > 0 {
+		reservation.Cancel()
+	}
+	return delay
+}
+
+// Middleware that rate limits requests by client IP, using app.limiter (see
+// config.Limiter). Requests over the limit receive a 429 Too
+// Many Requests response with a Retry-After header.
+//
+// The client IP is determined by clientIP, so X-Forwarded-For is only honored
+// for requests from trusted proxies. Probes, metrics scrapes and static files
+// aren't limited (see rateLimitExempt).
+func (app *application) rateLimit(next http.Handler) http.Handler {
+	if !app.config.Limiter.Enabled.value {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimitExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ip := clientIP(r, app.config.Limiter.TrustedProxies)
+
+		if delay := app.limiter.reserve(ip); delay > 0 {
+			app.requestLogger(r).Warn("rate limit exceeded", "ip", ip)
+			app.tooManyRequests(w, r, delay)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitExempt returns true if requests to path shouldn't be rate limited.
+// Health probes and metrics scrapes usually come from a single address at a
+// fixed interval, and limiting them could cause the orchestrator to restart a
+// healthy instance. Static files are requested in bursts by every page load.
+func rateLimitExempt(path string) bool {
+	switch path {
+	case "/ping", "/healthz", "/readyz", "/metrics":
+		return true
+	}
+	return strings.HasPrefix(path, "/static/")
+}
+
+// clientIP returns the IP address of the client that made the request.
+//
+// If the request came directly from the client, this is the host part of
+// r.RemoteAddr. If it came from one of the trusted proxies, the
+// X-Forwarded-For header is read from right to left, skipping any addresses
+// of trusted proxies, and the first remaining address is returned. The header
+// isn't honored for other requests, since clients can set it to anything.
+func clientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(ip, trustedProxies) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !isTrustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// isTrustedProxy returns true if ip is contained in one of the trustedProxies
+// networks.
+func isTrustedProxy(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// tooManyRequests sends a 429 Too Many Requests response, with a Retry-After
+// header giving the number of seconds until the client may try again. If the
+// client accepts HTML, a friendly error page is rendered. Otherwise a plain
+// text response is sent, as with clientError.
+func (app *application) tooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", fmt.Sprint(seconds))
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		app.clientError(w, http.StatusTooManyRequests)
+		return
+	}
+
+	// The rate limiter runs before the session is loaded, so the template data
+	// is initialized without newTemplateData.
+	data := templateData{
+		CurrentYear:  time.Now().Year(),
+		ErrorTitle:   "Slow down!",
+		ErrorMessage: fmt.Sprintf("You've made too many requests. Please try again in %d seconds.", seconds),
+	}
+	app.render(w, r, http.StatusTooManyRequests, "error.tmpl", data)
+}
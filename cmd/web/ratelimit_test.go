@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+// TestClientIP tests that X-Forwarded-For is only honored for requests from
+// trusted proxies.
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		expectedIP   string
+	}{
+		{"Direct Client", "203.0.113.5:1234", "", "203.0.113.5"},
+		{"Untrusted Proxy Ignored", "203.0.113.5:1234", "198.51.100.7", "203.0.113.5"},
+		{"Trusted Proxy", "10.1.2.3:1234", "198.51.100.7", "198.51.100.7"},
+		{"Trusted Single IP", "192.168.1.1:1234", "198.51.100.7", "198.51.100.7"},
+		{"Spoofed Leftmost Entry", "10.1.2.3:1234", "1.1.1.1, 198.51.100.7", "198.51.100.7"},
+		{"Chain of Trusted Proxies", "10.1.2.3:1234", "198.51.100.7, 10.9.9.9", "198.51.100.7"},
+		{"All Trusted", "10.1.2.3:1234", "10.4.4.4, 10.9.9.9", "10.4.4.4"},
+		{"Invalid Entry", "10.1.2.3:1234", "nonsense", "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+
+			assert.Equal(t, clientIP(r, trusted), tt.expectedIP)
+		})
+	}
+}
+
+// TestIPRateLimiter tests that requests beyond the burst are rejected, and
+// that each IP has its own bucket.
+func TestIPRateLimiter(t *testing.T) {
+	limiter := newIPRateLimiter(1, 2)
+
+	assert.Equal(t, limiter.reserve("203.0.113.5") == 0, true)
+	assert.Equal(t, limiter.reserve("203.0.113.5") == 0, true)
+	assert.Equal(t, limiter.reserve("203.0.113.5") > 0, true)
+	assert.Equal(t, limiter.reserve("198.51.100.7") == 0, true)
+}
+
+// TestIPRateLimiterCleanup tests that idle clients are removed, and that
+// cleanup returns once its context is cancelled.
+func TestIPRateLimiterCleanup(t *testing.T) {
+	limiter := newIPRateLimiter(1, 2)
+	limiter.reserve("203.0.113.5")
+	limiter.reserve("198.51.100.7")
+	limiter.clients["203.0.113.5"].lastSeen = time.Now().Add(-5 * time.Minute)
+
+	limiter.removeIdle(3 * time.Minute)
+
+	_, ok := limiter.clients["203.0.113.5"]
+	assert.Equal(t, ok, false)
+	_, ok = limiter.clients["198.51.100.7"]
+	assert.Equal(t, ok, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		limiter.cleanup(ctx)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("cleanup didn't return after its context was cancelled")
+	}
+}
+
+// TestRateLimitExempt tests that probes, metrics and static files are exempt
+// from rate limiting, and that other routes aren't.
+func TestRateLimitExempt(t *testing.T) {
+	tests := []struct {
+		path   string
+		exempt bool
+	}{
+		{"/healthz", true},
+		{"/readyz", true},
+		{"/metrics", true},
+		{"/ping", true},
+		{"/static/css/index.css", true},
+		{"/", false},
+		{"/contacts/view/1", false},
+		{"/healthzz", false},
+		{"/staticfile", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, rateLimitExempt(tt.path), tt.exempt)
+		})
+	}
+}
@@ -19,16 +19,58 @@ Dynamic unprotected routes:
   - GET  		/											   			display the home page
   - GET  		/about												display the about page
   - GET  		/ping 							  				responses with 200 OK
+  - GET  		/healthz 							  			liveness check, always 200 OK
+  - GET  		/readyz 							  			readiness check of dependencies (JSON)
+  - GET  		/metrics 							  			Prometheus metrics (unless -metrics-port is set)
+  - GET  		/events 							  			stream of contact change events (SSE)
   - GET  		/contacts/create   	   		    display form to create contacts
   - POST 		/contacts/create      				create a new contact
+  - GET     /contacts/import              display form to import contacts from a vCard file
+  - POST    /contacts/import              create contacts and their relations from a vCard file
   - GET  		/contacts/view/:id        		display a specific contact
   - GET  		/contacts/edit/:id        		display edit form a contact
   - POST 		/contacts/edit/:id        		edit a contact
   - GET     /contacts/delete/:id          display contact and prompts to delete
   - POST    /contacts/delete/:id          delete a contact
-
-Currently all HTTP requests are GET or POST. I intend to change this with
-HTMX at a later time.
+  - PUT     /contacts/:id                 update a contact from the inline edit form (HTMX)
+  - DELETE  /contacts/:id                 delete a contact (HTMX)
+  - POST    /contacts/validate/:field     validate a field of the contact form (HTMX)
+  - GET     /contacts/suggest-company     suggest a company from an email domain (HTMX)
+  - GET     /contacts/company-options     suggest companies matching the company field (HTMX)
+  - POST    /contacts/favorite/:id        mark or unmark a contact as a favorite
+  - GET     /contacts/relate/:id          search for contacts to relate to a contact (HTMX)
+  - POST    /contacts/relate/:id          add a relation to another contact
+  - POST    /contacts/unrelate/:id        remove a relation between contacts
+  - POST    /contacts/notes/:id           add a note or interaction to a contact's timeline
+  - POST    /notes/delete/:id             delete a note
+  - POST    /contacts/reminders/:id       add a follow-up reminder for a contact
+  - POST    /reminders/done/:id           complete a reminder, or move it to its next occurrence
+  - POST    /reminders/delete/:id         delete a reminder
+  - GET     /notifications                display notifications of fired reminders
+  - POST    /notifications/read           mark all notifications as read
+  - GET     /account/view                 display the account page, with the calendar feed settings
+  - POST    /account/calendar/rotate      replace the calendar feed's secret token
+  - GET     /calendar/:token.ics          iCalendar feed of birthdays and reminders
+  - POST    /contacts/bulk                export selected contacts, or confirm a bulk action
+  - POST    /contacts/bulk/confirm        apply a bulk action to the selected contacts
+  - POST    /contacts/share/:id           create a public share link for a contact
+  - GET     /shares                       display active share links
+  - POST    /shares/revoke/:id            revoke a share link
+  - GET     /s/:token                     public read-only page for a shared contact
+  - GET     /s/:token/contact.vcf         vCard download for a shared contact
+  - GET     /audit                        display the audit log, with filters
+  - GET     /audit/export                 download the audit log as CSV or JSON
+  - GET     /webhooks                     display webhooks and a form to add one
+  - POST    /webhooks/create              create a webhook subscription
+  - GET     /webhooks/view/:id            display a webhook and its deliveries
+  - POST    /webhooks/delete/:id          delete a webhook subscription
+  - GET     /companies                    display all companies
+  - GET     /companies/:id                display a company and everyone at it
+  - POST    /companies/:id                edit a company
+
+HTMX requests to some GET routes are answered with a fragment of the page
+rather than the whole page. See isHTMX and renderFragment. The POST routes
+remain, so that the forms work without JavaScript.
 */
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
@@ -48,9 +90,20 @@ func (app *application) routes() http.Handler {
 	)
 
 	router.HandlerFunc(http.MethodGet, "/ping", ping)
+	router.HandlerFunc(http.MethodGet, "/healthz", app.healthz)
+	router.HandlerFunc(http.MethodGet, "/readyz", app.readyz)
+	router.HandlerFunc(http.MethodGet, "/events", app.contactEvents)
+
+	// Calendar apps don't send cookies, so the feed doesn't use sessions.
+	router.HandlerFunc(http.MethodGet, "/calendar/:token", app.calendarFeed)
+
+	// Serve metrics here, unless they are served on a separate admin port.
+	if app.config.MetricsPort == 0 {
+		router.Handler(http.MethodGet, "/metrics", app.metrics.handler())
+	}
 
 	// Middleware chain for dynamic routes only (not static files).
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf)
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.logUserID, noSurf)
 
 	// Dynamic routes are wrapped in our dynamic middleware. Note that since
 	// ThenFunc returns an http.Handler, we need to use router.Handler instead of
@@ -65,11 +118,60 @@ func (app *application) routes() http.Handler {
 	router.Handler(http.MethodGet, "/contacts/delete/:id", dynamic.ThenFunc(app.contactDelete))
 	router.Handler(http.MethodPost, "/contacts/delete/:id", dynamic.ThenFunc(app.contactDeletePost))
 
+	router.Handler(http.MethodPut, "/contacts/:id", dynamic.ThenFunc(app.contactPut))
+	router.Handler(http.MethodDelete, "/contacts/:id", dynamic.ThenFunc(app.contactDeleteHX))
+	router.Handler(http.MethodPost, "/contacts/validate/:field", dynamic.ThenFunc(app.contactValidateField))
+	router.Handler(http.MethodGet, "/contacts/suggest-company", dynamic.ThenFunc(app.companySuggest))
+	router.Handler(http.MethodGet, "/contacts/company-options", dynamic.ThenFunc(app.companyOptions))
+	router.Handler(http.MethodPost, "/contacts/favorite/:id", dynamic.ThenFunc(app.contactFavoritePost))
+
+	router.Handler(http.MethodGet, "/contacts/relate/:id", dynamic.ThenFunc(app.contactRelatedOptions))
+	router.Handler(http.MethodPost, "/contacts/relate/:id", dynamic.ThenFunc(app.contactRelatePost))
+	router.Handler(http.MethodPost, "/contacts/unrelate/:id", dynamic.ThenFunc(app.contactUnrelatePost))
+
+	router.Handler(http.MethodPost, "/contacts/notes/:id", dynamic.ThenFunc(app.contactNotePost))
+	router.Handler(http.MethodPost, "/notes/delete/:id", dynamic.ThenFunc(app.noteDeletePost))
+
+	router.Handler(http.MethodPost, "/contacts/reminders/:id", dynamic.ThenFunc(app.contactReminderPost))
+	router.Handler(http.MethodPost, "/reminders/done/:id", dynamic.ThenFunc(app.reminderDonePost))
+	router.Handler(http.MethodPost, "/reminders/delete/:id", dynamic.ThenFunc(app.reminderDeletePost))
+	router.Handler(http.MethodGet, "/notifications", dynamic.ThenFunc(app.notificationList))
+	router.Handler(http.MethodPost, "/notifications/read", dynamic.ThenFunc(app.notificationReadPost))
+
+	router.Handler(http.MethodGet, "/account/view", dynamic.ThenFunc(app.accountView))
+	router.Handler(http.MethodPost, "/account/calendar/rotate", dynamic.ThenFunc(app.calendarRotatePost))
+
+	router.Handler(http.MethodPost, "/contacts/bulk", dynamic.ThenFunc(app.contactBulkPost))
+	router.Handler(http.MethodPost, "/contacts/bulk/confirm", dynamic.ThenFunc(app.contactBulkConfirmPost))
+
 	router.Handler(http.MethodGet, "/contacts/create", dynamic.ThenFunc(app.contactCreate))
 	router.Handler(http.MethodPost, "/contacts/create", dynamic.ThenFunc(app.contactCreatePost))
 
+	// The request's size is limited before noSurf reads the form.
+	importLimit := alice.New(app.limitBody(maxImportBodySize)).Extend(dynamic)
+	router.Handler(http.MethodGet, "/contacts/import", dynamic.ThenFunc(app.contactImport))
+	router.Handler(http.MethodPost, "/contacts/import", importLimit.ThenFunc(app.contactImportPost))
+
+	router.Handler(http.MethodPost, "/contacts/share/:id", dynamic.ThenFunc(app.contactSharePost))
+	router.Handler(http.MethodGet, "/shares", dynamic.ThenFunc(app.shareList))
+	router.Handler(http.MethodPost, "/shares/revoke/:id", dynamic.ThenFunc(app.shareRevokePost))
+	router.Handler(http.MethodGet, "/s/:token", dynamic.ThenFunc(app.sharedContactView))
+	router.Handler(http.MethodGet, "/s/:token/contact.vcf", dynamic.ThenFunc(app.sharedContactVCard))
+
+	router.Handler(http.MethodGet, "/audit", dynamic.ThenFunc(app.auditLogView))
+	router.Handler(http.MethodGet, "/audit/export", dynamic.ThenFunc(app.auditLogExport))
+
+	router.Handler(http.MethodGet, "/webhooks", dynamic.ThenFunc(app.webhookList))
+	router.Handler(http.MethodPost, "/webhooks/create", dynamic.ThenFunc(app.webhookCreatePost))
+	router.Handler(http.MethodGet, "/webhooks/view/:id", dynamic.ThenFunc(app.webhookView))
+	router.Handler(http.MethodPost, "/webhooks/delete/:id", dynamic.ThenFunc(app.webhookDeletePost))
+
+	router.Handler(http.MethodGet, "/companies", dynamic.ThenFunc(app.companyList))
+	router.Handler(http.MethodGet, "/companies/:id", dynamic.ThenFunc(app.companyView))
+	router.Handler(http.MethodPost, "/companies/:id", dynamic.ThenFunc(app.companyEditPost))
+
 	// Initialize chain of standard pre-request middlewares.
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	standard := alice.New(requestID, app.logRequest, app.collectMetrics(router), app.recoverPanic, secureHeaders, app.rateLimit)
 
 	return standard.Then(router)
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout is the maximum time to wait for in-flight requests to
+// complete once the server has begun shutting down.
+const shutdownTimeout = 30 * time.Second
+
+// serve runs srv until it receives a SIGINT or SIGTERM signal, and then shuts
+// it down gracefully.
+//
+// On receiving a signal, the application is marked as shutting down, which
+// causes GET /readyz to fail. The server keeps serving requests for
+// config.DrainTime, to give load balancers time to notice and stop routing
+// traffic to this instance, and then waits for in-flight requests to complete
+// before returning.
+func (app *application) serve(srv *http.Server) error {
+	shutdownErr := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.shuttingDown.Store(true)
+		app.logger.Info("shutting down server", "signal", s.String(), "drain_time", app.config.DrainTime)
+		time.Sleep(app.config.DrainTime)
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		shutdownErr <- srv.Shutdown(ctx)
+	}()
+
+	// ListenAndServe returns http.ErrServerClosed immediately when Shutdown is
+	// called, so this is only an error if something else went wrong.
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	// Wait for Shutdown to finish.
+	err = <-shutdownErr
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("stopped server")
+	return nil
+}
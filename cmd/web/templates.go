@@ -4,9 +4,12 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"slices"
 	"time"
 
+	"github.com/kvnloughead/contacts-app/internal/markdown"
 	"github.com/kvnloughead/contacts-app/internal/models"
+	"github.com/kvnloughead/contacts-app/internal/reminders"
 	"github.com/kvnloughead/contacts-app/ui"
 )
 
@@ -21,23 +24,108 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// Returns a human readable date without a time, formatted as 'DD Mon YYYY'.
+// If t is the zero time, an empty string is returned.
+func humanDay(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format("02 Jan 2006")
+}
+
+// formField contains what's needed to render a single form input with its
+// label and error. See the contact-field template.
+type formField struct {
+	Name  string
+	Label string
+	Value string
+	Error string
+}
+
+// newFormField returns a formField, for use in templates. The error is looked
+// up by name in fieldErrors, which is usually the form's FieldErrors.
+func newFormField(name, label, value string, fieldErrors map[string]string) formField {
+	return formField{Name: name, Label: label, Value: value, Error: fieldErrors[name]}
+}
+
 // template.FuncMap struct provides a string keyed map of template functions.
 // Must be registered with the template before calling ParseFiles.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":  humanDate,
+	"humanDay":   humanDay,
+	"isoDate":    formatBirthday,
+	"contains":   slices.Contains[[]string],
+	"field":      newFormField,
+	"markdown":   markdown.Render,
+	"recurrence": describeRecurrence,
+}
+
+// describeRecurrence returns a description of a reminder's recurrence rule,
+// such as "every 2 weeks", or an empty string if it doesn't repeat.
+func describeRecurrence(rule string) string {
+	r, err := reminders.ParseRule(rule)
+	if err != nil {
+		return ""
+	}
+	return r.Describe()
 }
 
 // Go templates only allow a single data argument, so we create a struct to
 // store all necessary template data.
+//
+// User is the signed in user, whose details are shown on the account page. It
+// is nil until there are user accounts.
 type templateData struct {
 	CurrentYear     int
 	Contact         models.Contact
 	Contacts        []models.Contact
+	Shares          []models.Share
+	ShareURL        string
+	ShareToken      string
+	AuditEvents     []models.AuditEvent
+	AuditActions    []string
+	Pagination      pagination
+	Webhooks        []models.WebhookSubscription
+	Webhook         models.WebhookSubscription
+	WebhookSecret   string
+	Companies       []models.Company
+	Company         models.Company
+	Relations       []models.Relation
+	RelatedQuery    string
+	RelationTypes   []models.RelationType
+	Notes           []models.Note
+	NoteKinds       []formField
+	Reminders       []models.Reminder
+	Recurrences     []formField
+	ReminderForm    any
+	Notifications   []models.Notification
+	User            any
+	CalendarURL     string
+	CalendarCreated time.Time
+	Sort            string
+	Favorites       []models.Contact
+	RecentlyViewed  []models.Contact
+	ViewOrder       string
+	Deliveries      []models.WebhookDelivery
+	EventTypes      []string
 	Form            any
+	Conflicts       []fieldConflict
+	BulkFields      []formField
 	Flash           string
 	IsAuthenticated bool
 	CSRFToken       string
 	DeleteForm      bool
+	ErrorTitle      string
+	ErrorMessage    string
+}
+
+// pagination contains the pagination metadata for a list page, and the URLs
+// of the previous and next pages. The URLs are empty if there is no such page.
+type pagination struct {
+	models.Metadata
+	PrevURL string
+	NextURL string
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -0,0 +1,181 @@
+// Package events broadcasts contact change events to subscribers, such as the
+// Server-Sent Events stream served at GET /events.
+//
+// Events are received from Postgres with LISTEN/NOTIFY. A trigger on the
+// outbox_events table sends a notification on Channel for each event when the
+// transaction that wrote it commits, so every instance of the application
+// receives every change, whichever instance made it. Notification payloads are
+// limited in size, so they only carry the event's ID and type, and the
+// event's payload is loaded from the outbox by the Broker.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel that contact change events are
+// sent on.
+const Channel = "contact_events"
+
+// Resync is the type of the event published after the connection to Postgres
+// has been lost and reestablished. Notifications sent in the meantime are
+// lost, so subscribers should assume they have missed events.
+const Resync = "resync"
+
+// subscriberBuffer is the number of events buffered for each subscriber.
+// Events for subscribers that fall further behind are dropped.
+const subscriberBuffer = 16
+
+// Event is a contact change event. Data is the JSON encoded
+// models.EventPayload, or an empty object for Resync events.
+type Event struct {
+	Type string
+	Data []byte
+}
+
+// Broker broadcasts events to all current subscribers. The zero value is not
+// usable; use NewBroker.
+type Broker struct {
+	Logger *slog.Logger
+
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	closed      bool
+}
+
+// NewBroker returns a Broker with no subscribers.
+func NewBroker(logger *slog.Logger) *Broker {
+	return &Broker{Logger: logger, subscribers: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel that receives all events published from now on,
+// and a function to unsubscribe, which must be called when the subscriber is
+// done. The channel is closed when the broker is closed.
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, subscriberBuffer)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish sends e to all subscribers. It never blocks: if a subscriber's
+// buffer is full, the event is dropped for that subscriber.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			b.Logger.Warn("dropped event for slow subscriber", "event", e.Type)
+		}
+	}
+}
+
+// Close closes all subscribers' channels, and causes future subscribers to
+// receive a closed channel. It is called when the server shuts down, so that
+// open event streams end.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// PayloadStore loads the payloads of outbox events. It is implemented by
+// models.WebhookModel.
+type PayloadStore interface {
+	GetEventPayload(ctx context.Context, id int) ([]byte, error)
+}
+
+// notification is the JSON payload of a notification on Channel.
+type notification struct {
+	ID    int    `json:"id"`
+	Event string `json:"event"`
+}
+
+// Listen listens for notifications on Channel, using a dedicated connection
+// to the database with the given DSN, and publishes the events they refer to,
+// loaded from store, until ctx is cancelled. The connection is reestablished
+// automatically if it is lost, in which case a Resync event is published.
+func (b *Broker) Listen(ctx context.Context, dsn string, store PayloadStore) error {
+	listener := pq.NewListener(dsn, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			b.Logger.Error("event listener", "error", err.Error())
+		}
+	})
+	defer listener.Close()
+
+	err := listener.Listen(Channel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case n := <-listener.Notify:
+			// A nil notification is sent after reconnecting.
+			if n == nil {
+				b.Publish(Event{Type: Resync, Data: []byte("{}")})
+				continue
+			}
+
+			b.handle(ctx, store, n.Extra)
+
+		// Check the connection periodically, since a dead connection otherwise
+		// goes unnoticed until the next notification is due.
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
+
+// handle loads the payload of the event referred to by a notification from
+// store, and publishes the event. Errors are logged, and the event is skipped.
+func (b *Broker) handle(ctx context.Context, store PayloadStore, extra string) {
+	var n notification
+	err := json.Unmarshal([]byte(extra), &n)
+	if err != nil || n.ID == 0 {
+		b.Logger.Error("invalid event notification", "notification", extra)
+		return
+	}
+
+	data, err := store.GetEventPayload(ctx, n.ID)
+	if err != nil {
+		b.Logger.Error("loading event payload failed", "event_id", n.ID, "error", err.Error())
+		return
+	}
+
+	b.Publish(Event{Type: n.Event, Data: data})
+}
@@ -0,0 +1,77 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestBroker(t *testing.T) {
+	b := NewBroker(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	first, unsubscribeFirst := b.Subscribe()
+	second, unsubscribeSecond := b.Subscribe()
+
+	b.Publish(Event{Type: "contact.updated", Data: []byte(`{"id":1}`)})
+	assert.Equal(t, (<-first).Type, "contact.updated")
+	assert.Equal(t, (<-second).Type, "contact.updated")
+
+	// Unsubscribed channels are closed and receive no further events.
+	unsubscribeFirst()
+	unsubscribeFirst()
+	b.Publish(Event{Type: "contact.deleted"})
+	_, ok := <-first
+	assert.Equal(t, ok, false)
+	assert.Equal(t, (<-second).Type, "contact.deleted")
+
+	// Events are dropped rather than blocking when a buffer is full.
+	for range subscriberBuffer + 1 {
+		b.Publish(Event{Type: "contact.created"})
+	}
+	assert.Equal(t, len(second), subscriberBuffer)
+
+	// Closing the broker closes all channels, including those of later
+	// subscribers.
+	b.Close()
+	unsubscribeSecond()
+	for range second {
+	}
+	third, _ := b.Subscribe()
+	_, ok = <-third
+	assert.Equal(t, ok, false)
+}
+
+// fakeStore is a PayloadStore holding payloads in memory.
+type fakeStore map[int][]byte
+
+func (s fakeStore) GetEventPayload(ctx context.Context, id int) ([]byte, error) {
+	payload, ok := s[id]
+	if !ok {
+		return nil, errors.New("no such event")
+	}
+	return payload, nil
+}
+
+func TestBrokerHandle(t *testing.T) {
+	b := NewBroker(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	store := fakeStore{7: []byte(`{"event":"contact.created","contact":{"id":3}}`)}
+
+	events, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+
+	// The payload is loaded from the store, not taken from the notification.
+	b.handle(context.Background(), store, `{"id":7,"event":"contact.created"}`)
+	e := <-events
+	assert.Equal(t, e.Type, "contact.created")
+	assert.Equal(t, string(e.Data), `{"event":"contact.created","contact":{"id":3}}`)
+
+	// Invalid notifications and missing events are skipped.
+	b.handle(context.Background(), store, `not json`)
+	b.handle(context.Background(), store, `{"event":"contact.created"}`)
+	b.handle(context.Background(), store, `{"id":8,"event":"contact.deleted"}`)
+	assert.Equal(t, len(events), 0)
+}
@@ -0,0 +1,147 @@
+// Package ical encodes contact birthdays and reminders as an iCalendar feed,
+// as specified by RFC 5545. https://datatracker.ietf.org/doc/html/rfc5545
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+// ContentType is the media type of iCalendar data.
+const ContentType = "text/calendar; charset=utf-8"
+
+// maxLineLength is the maximum length of a content line in octets, excluding
+// the line break. Longer lines must be folded.
+const maxLineLength = 75
+
+// Formats of DATE and UTC DATE-TIME values.
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405Z"
+)
+
+// Feed is the content of a calendar feed. BaseURL is the application's
+// absolute URL, such as "https://contacts.example.com", which is used to link
+// to contacts and to make globally unique UIDs.
+type Feed struct {
+	Name      string
+	BaseURL   string
+	Contacts  []models.Contact
+	Reminders []models.Reminder
+}
+
+// Write writes the feed to w as a VCALENDAR. Each contact with a birthday is
+// written as a yearly recurring all-day VEVENT, and each reminder as a VTODO.
+// now is used as the DTSTAMP of every component.
+func Write(w io.Writer, feed Feed, now time.Time) error {
+	bw := bufio.NewWriter(w)
+
+	host := feed.BaseURL
+	if u, err := url.Parse(feed.BaseURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	stamp := now.UTC().Format(dateTimeLayout)
+
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:-//Contact.app//Contacts//EN")
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	if feed.Name != "" {
+		// Not part of RFC 5545, but widely used by clients to name subscriptions.
+		writeLine(bw, "X-WR-CALNAME:"+escape(feed.Name))
+	}
+
+	for _, c := range feed.Contacts {
+		if c.Birthday.IsZero() {
+			continue
+		}
+		name := strings.TrimSpace(c.First + " " + c.Last)
+
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, fmt.Sprintf("UID:birthday-%d@%s", c.ID, host))
+		writeLine(bw, "DTSTAMP:"+stamp)
+		writeLine(bw, "DTSTART;VALUE=DATE:"+c.Birthday.Format(dateLayout))
+		writeLine(bw, "RRULE:"+birthdayRule(c.Birthday))
+		writeLine(bw, "SUMMARY:"+escape(name+"'s birthday"))
+		writeLine(bw, fmt.Sprintf("URL:%s/contacts/view/%d", feed.BaseURL, c.ID))
+		writeLine(bw, "TRANSP:TRANSPARENT")
+		writeLine(bw, "END:VEVENT")
+	}
+
+	for _, r := range feed.Reminders {
+		due := r.Due.UTC().Format(dateTimeLayout)
+
+		writeLine(bw, "BEGIN:VTODO")
+		writeLine(bw, fmt.Sprintf("UID:reminder-%d@%s", r.ID, host))
+		writeLine(bw, "DTSTAMP:"+stamp)
+		if r.Recurrence != "" {
+			// A recurrence is relative to DTSTART, so it must be present.
+			writeLine(bw, "DTSTART:"+due)
+		}
+		writeLine(bw, "DUE:"+due)
+		if r.Recurrence != "" {
+			writeLine(bw, "RRULE:"+r.Recurrence)
+		}
+		writeLine(bw, "SUMMARY:"+escape("Follow up with "+r.ContactName))
+		if r.Note != "" {
+			writeLine(bw, "DESCRIPTION:"+escape(r.Note))
+		}
+		writeLine(bw, fmt.Sprintf("URL:%s/contacts/view/%d", feed.BaseURL, r.ContactID))
+		writeLine(bw, "STATUS:NEEDS-ACTION")
+		writeLine(bw, "END:VTODO")
+	}
+
+	writeLine(bw, "END:VCALENDAR")
+
+	return bw.Flush()
+}
+
+// birthdayRule returns the RRULE of a birthday. A yearly rule starting on 29
+// February only recurs in leap years, so those birthdays instead recur on the
+// last day of February.
+func birthdayRule(birthday time.Time) string {
+	if birthday.Month() == time.February && birthday.Day() == 29 {
+		return "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1"
+	}
+	return "FREQ=YEARLY"
+}
+
+// escape escapes a TEXT value. Backslashes, commas and semicolons are escaped
+// with a backslash, and newlines are replaced by \n.
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		",", `\,`,
+		";", `\;`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// writeLine writes a content line, terminated by CRLF. Lines longer than
+// maxLineLength octets are folded by inserting CRLF followed by a space,
+// taking care not to split multi-octet UTF-8 characters.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(line[i]) {
+			i--
+		}
+		w.WriteString(line[:i])
+		w.WriteString("\r\n ")
+		line = line[i:]
+
+		// Continuation lines begin with a space, which counts towards the limit.
+		limit = maxLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
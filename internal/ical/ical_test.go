@@ -0,0 +1,152 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestWrite(t *testing.T) {
+	feed := Feed{
+		Name:    "Contacts",
+		BaseURL: "https://contacts.example.com",
+		Contacts: []models.Contact{
+			{ID: 1, First: "Jane", Last: "Doe", Birthday: time.Date(1990, 3, 5, 0, 0, 0, 0, time.UTC)},
+			{ID: 2, First: "John", Last: "Doe"},
+		},
+		Reminders: []models.Reminder{
+			{ID: 3, ContactID: 1, ContactName: "Jane Doe", Due: time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC), Note: "Call back"},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := Write(&buf, feed, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//Contact.app//Contacts//EN\r\n" +
+		"CALSCALE:GREGORIAN\r\n" +
+		"X-WR-CALNAME:Contacts\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:birthday-1@contacts.example.com\r\n" +
+		"DTSTAMP:20240301T120000Z\r\n" +
+		"DTSTART;VALUE=DATE:19900305\r\n" +
+		"RRULE:FREQ=YEARLY\r\n" +
+		"SUMMARY:Jane Doe's birthday\r\n" +
+		"URL:https://contacts.example.com/contacts/view/1\r\n" +
+		"TRANSP:TRANSPARENT\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:reminder-3@contacts.example.com\r\n" +
+		"DTSTAMP:20240301T120000Z\r\n" +
+		"DUE:20240312T090000Z\r\n" +
+		"SUMMARY:Follow up with Jane Doe\r\n" +
+		"DESCRIPTION:Call back\r\n" +
+		"URL:https://contacts.example.com/contacts/view/1\r\n" +
+		"STATUS:NEEDS-ACTION\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, buf.String())
+	}
+}
+
+func TestWriteRecurringReminder(t *testing.T) {
+	feed := Feed{Reminders: []models.Reminder{
+		{ID: 1, Due: time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC), Recurrence: "FREQ=WEEKLY;INTERVAL=2"},
+	}}
+
+	var buf bytes.Buffer
+	err := Write(&buf, feed, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"DTSTART:20240312T090000Z\r\nDUE:20240312T090000Z\r\n",
+		"RRULE:FREQ=WEEKLY;INTERVAL=2\r\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%q", expected, buf.String())
+		}
+	}
+}
+
+func TestBirthdayRule(t *testing.T) {
+	testCases := []struct {
+		name     string
+		birthday time.Time
+		expected string
+	}{
+		{"Ordinary Day", time.Date(1990, 3, 5, 0, 0, 0, 0, time.UTC), "FREQ=YEARLY"},
+		{"Leap Day", time.Date(1992, 2, 29, 0, 0, 0, 0, time.UTC), "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := birthdayRule(tc.birthday); got != tc.expected {
+				t.Errorf("Test %s failed. Expected %q, got %q", tc.name, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"No Special Characters", "Call back", "Call back"},
+		{"Comma", "Doe, Jr.", `Doe\, Jr.`},
+		{"Semicolon", "a;b", `a\;b`},
+		{"Backslash", `a\b`, `a\\b`},
+		{"Newline", "a\nb", `a\nb`},
+		{"CRLF", "a\r\nb", `a\nb`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := escape(tc.value); got != tc.expected {
+				t.Errorf("Test %s failed. Expected %q, got %q", tc.name, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestLineFolding(t *testing.T) {
+	feed := Feed{Reminders: []models.Reminder{
+		{ID: 1, ContactName: "Jane Doe", Due: now, Note: strings.Repeat("é", 100)},
+	}}
+
+	var buf bytes.Buffer
+	err := Write(&buf, feed, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("Line exceeds %d octets: %q", maxLineLength, line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("Line splits a multi-octet character: %q", line)
+		}
+	}
+
+	// Unfolding the lines should restore the description.
+	unfolded := strings.ReplaceAll(buf.String(), "\r\n ", "")
+	if !strings.Contains(unfolded, "DESCRIPTION:"+strings.Repeat("é", 100)+"\r\n") {
+		t.Errorf("Expected unfolded output to contain the description, got:\n%q", unfolded)
+	}
+}
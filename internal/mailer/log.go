@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LogMailer is a Mailer for local development and tests. Instead of sending
+// messages it logs them, and if Dir is set, writes each one to a .eml file in
+// Dir that can be opened with an email client.
+type LogMailer struct {
+	Logger *slog.Logger
+	Dir    string
+	Sender string
+}
+
+// Send logs the message and writes it to Dir, if set.
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	body, err := msg.build(m.Sender)
+	if err != nil {
+		return err
+	}
+
+	if m.Dir == "" {
+		m.Logger.Info("email not sent (no SMTP server configured)",
+			"to", msg.To,
+			"subject", msg.Subject,
+			"body", msg.PlainBody,
+		)
+		return nil
+	}
+
+	name := fmt.Sprintf("%s.eml", time.Now().Format("20060102T150405.000000000"))
+	path := filepath.Join(m.Dir, name)
+	err = os.WriteFile(path, body, 0o600)
+	if err != nil {
+		return err
+	}
+
+	m.Logger.Info("email written to file", "to", msg.To, "subject", msg.Subject, "path", path)
+	return nil
+}
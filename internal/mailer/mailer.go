@@ -0,0 +1,83 @@
+// Package mailer provides a Mailer interface for sending emails, with an SMTP
+// implementation for production and a log-backed implementation for local
+// development and tests.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"time"
+)
+
+// Message is an email message. PlainBody is required, and HTMLBody is
+// optional. If HTMLBody is set, the message is sent as multipart/alternative
+// so that clients can choose which to display.
+type Message struct {
+	To        string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+}
+
+// Mailer is the interface implemented by the application's mailers.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// boundary separates the parts of multipart messages.
+const boundary = "contacts-app-boundary"
+
+// build returns msg as an RFC 5322 message from the given sender, suitable for
+// sending over SMTP or writing to a .eml file.
+func (msg Message) build(sender string) ([]byte, error) {
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return nil, fmt.Errorf("mailer: invalid recipient %q: %w", msg.To, err)
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", sender)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+
+	if msg.HTMLBody == "" {
+		writePart(&buf, "text/plain", msg.PlainBody)
+		return buf.Bytes(), nil
+	}
+
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	writePart(&buf, "text/plain", msg.PlainBody)
+	fmt.Fprintf(&buf, "\r\n--%s\r\n", boundary)
+	writePart(&buf, "text/html", msg.HTMLBody)
+	fmt.Fprintf(&buf, "\r\n--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+// writePart writes the headers and quoted-printable encoded body of a single
+// message part to buf.
+func writePart(buf *bytes.Buffer, contentType, body string) {
+	fmt.Fprintf(buf, "Content-Type: %s; charset=utf-8\r\n", contentType)
+	fmt.Fprintf(buf, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(buf)
+	w.Write([]byte(body))
+	w.Close()
+}
+
+// envelopeAddress returns the bare email address from an address that may
+// include a display name, such as "Name <name@example.com>".
+func envelopeAddress(address string) (string, error) {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("mailer: invalid address %q: %w", address, err)
+	}
+	return parsed.Address, nil
+}
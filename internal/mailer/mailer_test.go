@@ -0,0 +1,130 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageBuild(t *testing.T) {
+	testCases := []struct {
+		name      string
+		msg       Message
+		valid     bool
+		multipart bool
+	}{
+		{"Plain Only", Message{To: "a@example.com", Subject: "Hi", PlainBody: "Hello"}, true, false},
+		{"Plain and HTML", Message{To: "a@example.com", Subject: "Hi", PlainBody: "Hello", HTMLBody: "<p>Hello</p>"}, true, true},
+		{"Display Name", Message{To: "A <a@example.com>", Subject: "Hi", PlainBody: "Hello"}, true, false},
+		{"Invalid Recipient", Message{To: "not an address", Subject: "Hi", PlainBody: "Hello"}, false, false},
+		{"Header Injection", Message{To: "a@example.com\r\nBcc: b@example.com", Subject: "Hi", PlainBody: "Hello"}, false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.msg.build("Contact.app <no-reply@example.com>")
+			if (err == nil) != tc.valid {
+				t.Fatalf("Test %s failed. Expected valid to be %t, got error %v", tc.name, tc.valid, err)
+			}
+			if !tc.valid {
+				return
+			}
+
+			multipart := strings.Contains(string(b), "multipart/alternative")
+			if multipart != tc.multipart {
+				t.Errorf("Test %s failed. Expected multipart to be %t, got %t", tc.name, tc.multipart, multipart)
+			}
+		})
+	}
+}
+
+func TestLogMailerWritesFile(t *testing.T) {
+	dir := t.TempDir()
+	m := &LogMailer{
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Dir:    dir,
+		Sender: "no-reply@example.com",
+	}
+
+	err := m.Send(context.Background(), Message{To: "a@example.com", Subject: "Reminder", PlainBody: "Call Bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 .eml file, got %d", len(files))
+	}
+
+	b, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(b), "Subject: Reminder") {
+		t.Errorf("Expected file to contain the subject, got:\n%s", b)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"Network Error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"Temporary Reply", &textproto.Error{Code: 451, Msg: "Try again later"}, true},
+		{"Permanent Reply", &textproto.Error{Code: 550, Msg: "No such user"}, false},
+		{"Wrapped Permanent Reply", fmt.Errorf("send: %w", &textproto.Error{Code: 553, Msg: "Bad address"}), false},
+		{"Other Error", errors.New("smtp: server doesn't support AUTH"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := retryable(tc.err); got != tc.retryable {
+				t.Errorf("Test %s failed. Expected retryable to be %t, got %t", tc.name, tc.retryable, got)
+			}
+		})
+	}
+}
+
+// TestSMTPMailerCancel tests that Send gives up when its context is cancelled,
+// even if the server never replies.
+func TestSMTPMailerCancel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// Accept connections, but never send the greeting.
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	m := &SMTPMailer{Host: "127.0.0.1", Port: addr.Port, Sender: "no-reply@example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = m.Send(ctx, Message{To: "a@example.com", Subject: "Reminder", PlainBody: "Call Bob"})
+	if err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Send to return soon after the context was cancelled, took %s", elapsed)
+	}
+}
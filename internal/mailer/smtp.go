@@ -0,0 +1,151 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// SMTPMailer sends emails through an SMTP server. If Username is empty, no
+// authentication is attempted.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+
+	// The From address, such as "Contact.app <no-reply@example.com>".
+	Sender string
+
+	// The maximum time a single attempt may take, including connecting to the
+	// server. If zero, there is no limit other than the context's deadline.
+	Timeout time.Duration
+}
+
+// Send sends the message, making up to three attempts in case of a transient
+// error (see retryable). It gives up when ctx is cancelled, even during an
+// attempt.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	body, err := msg.build(m.Sender)
+	if err != nil {
+		return err
+	}
+
+	from, err := envelopeAddress(m.Sender)
+	if err != nil {
+		return err
+	}
+	to, err := envelopeAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= 3; i++ {
+		if i > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(500 * time.Millisecond):
+			}
+		}
+		err = m.send(ctx, from, to, body)
+		if err == nil || !retryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// send makes a single attempt to send the message, in the same way as
+// smtp.SendMail, but with a deadline on the connection. The deadline is the
+// sooner of Timeout and ctx's deadline, and is moved to now if ctx is
+// cancelled, which interrupts any read or write in progress.
+func (m *SMTPMailer) send(ctx context.Context, from, to string, body []byte) error {
+	if m.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", m.Host, m.Port))
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	c, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: m.Host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		err = c.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(to)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// retryable returns true if err may succeed on another attempt. SMTP replies
+// with 5xx codes, such as a rejected recipient, are permanent failures and
+// aren't retried, while 4xx replies, such as a full mailbox or greylisting,
+// are temporary. Network errors are always retried.
+func retryable(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
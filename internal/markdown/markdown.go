@@ -0,0 +1,38 @@
+// Package markdown renders user-written Markdown, such as contact notes, to
+// HTML that is safe to include in a page.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// renderer converts GitHub Flavored Markdown to HTML. Raw HTML in the source
+// is omitted, since the renderer isn't configured with html.WithUnsafe.
+var renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// policy removes anything that could run scripts or change the page from the
+// rendered HTML, in case the renderer lets something through. Links are
+// given rel="nofollow noopener" and open in a new tab.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+// Render returns the Markdown source rendered as sanitized HTML. If the source
+// can't be rendered, it is returned escaped, as plain text.
+func Render(src string) template.HTML {
+	var buf bytes.Buffer
+	err := renderer.Convert([]byte(src), &buf)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(src))
+	}
+
+	return template.HTML(policy.SanitizeBytes(buf.Bytes()))
+}
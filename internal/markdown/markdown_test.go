@@ -0,0 +1,62 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	testCases := []struct {
+		name     string
+		src      string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "Emphasis",
+			src:      "Call **Tuesday**",
+			contains: []string{"<strong>Tuesday</strong>"},
+		},
+		{
+			name:     "List",
+			src:      "- one\n- two",
+			contains: []string{"<li>one</li>", "<li>two</li>"},
+		},
+		{
+			name:     "Link",
+			src:      "[site](https://example.com)",
+			contains: []string{`href="https://example.com"`, `rel="nofollow noopener"`},
+		},
+		{
+			name:     "Script Tag",
+			src:      "hi <script>alert(1)</script>",
+			excludes: []string{"<script", "alert(1)</script>"},
+		},
+		{
+			name:     "Event Handler",
+			src:      `<img src="x" onerror="alert(1)">`,
+			excludes: []string{"onerror"},
+		},
+		{
+			name:     "JavaScript Link",
+			src:      "[click](javascript:alert(1))",
+			excludes: []string{"javascript:"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			html := string(Render(tc.src))
+			for _, s := range tc.contains {
+				if !strings.Contains(html, s) {
+					t.Errorf("Expected %q to contain %q", html, s)
+				}
+			}
+			for _, s := range tc.excludes {
+				if strings.Contains(html, s) {
+					t.Errorf("Expected %q not to contain %q", html, s)
+				}
+			}
+		})
+	}
+}
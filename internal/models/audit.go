@@ -0,0 +1,263 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditContactCreated    = "contact.created"
+	AuditContactUpdated    = "contact.updated"
+	AuditContactDeleted    = "contact.deleted"
+	AuditShareCreated      = "share.created"
+	AuditShareRevoked      = "share.revoked"
+	AuditLogExported       = "audit.exported"
+	AuditWebhookCreated    = "webhook.created"
+	AuditWebhookDeleted    = "webhook.deleted"
+	AuditCompanyUpdated    = "company.updated"
+	AuditRelationAdded     = "relation.added"
+	AuditRelationRemoved   = "relation.removed"
+	AuditNoteAdded         = "note.added"
+	AuditNoteDeleted       = "note.deleted"
+	AuditReminderAdded     = "reminder.added"
+	AuditReminderCompleted = "reminder.completed"
+	AuditReminderDeleted   = "reminder.deleted"
+	AuditCalendarRotated   = "calendar.rotated"
+)
+
+// AuditActions lists all of the actions recorded in the audit log.
+var AuditActions = []string{
+	AuditContactCreated,
+	AuditContactUpdated,
+	AuditContactDeleted,
+	AuditShareCreated,
+	AuditShareRevoked,
+	AuditLogExported,
+	AuditWebhookCreated,
+	AuditWebhookDeleted,
+	AuditCompanyUpdated,
+	AuditRelationAdded,
+	AuditRelationRemoved,
+	AuditNoteAdded,
+	AuditNoteDeleted,
+	AuditReminderAdded,
+	AuditReminderCompleted,
+	AuditReminderDeleted,
+	AuditCalendarRotated,
+}
+
+// AuditEvent is a struct representing an entry in the audit log. Before and
+// After contain JSON summaries of the target before and after the action, and
+// are nil if not applicable (for example, Before is nil for a created
+// contact). ActorID is 0 for actions taken by anonymous users.
+type AuditEvent struct {
+	ID         int             `json:"id"`
+	Created    time.Time       `json:"created"`
+	ActorID    int             `json:"actor_id,omitempty"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int             `json:"target_id"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditFilter contains the criteria for listing audit events. Zero valued
+// fields are ignored. Page and PageSize are only used by GetAll.
+type AuditFilter struct {
+	Action   string
+	TargetID int
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+}
+
+// Metadata contains pagination information for a list of records.
+type Metadata struct {
+	CurrentPage  int
+	PageSize     int
+	LastPage     int
+	TotalRecords int
+}
+
+// newMetadata calculates pagination metadata from the total number of
+// records, the current page and the page size.
+func newMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{CurrentPage: page, PageSize: pageSize, LastPage: 1}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		LastPage:     (totalRecords + pageSize - 1) / pageSize,
+		TotalRecords: totalRecords,
+	}
+}
+
+// AuditModel is a wrapper for our sql.DB connection pool.
+// Contains methods for interacting with the append-only audit_events table.
+type AuditModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type AuditModelInterface interface {
+	Insert(ctx context.Context, event AuditEvent) error
+	GetAll(ctx context.Context, filter AuditFilter) ([]AuditEvent, Metadata, error)
+	Export(ctx context.Context, filter AuditFilter, fn func(AuditEvent) error) error
+}
+
+// Insert adds an event to the audit log. The ID and Created fields of the
+// event are ignored, and set by the database.
+func (m *AuditModel) Insert(ctx context.Context, e AuditEvent) error {
+	query := `
+		INSERT INTO audit_events
+			(actor_id, action, target_type, target_id, ip, request_id, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	args := []any{
+		sql.NullInt64{Int64: int64(e.ActorID), Valid: e.ActorID != 0},
+		e.Action, e.TargetType, e.TargetID, e.IP, e.RequestID,
+		nullJSON(e.Before), nullJSON(e.After),
+	}
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	return nil
+}
+
+// nullJSON converts a JSON value into an argument for a nullable jsonb column.
+func nullJSON(js json.RawMessage) any {
+	if len(js) == 0 {
+		return nil
+	}
+	return string(js)
+}
+
+// auditFilterClause is the WHERE clause shared by GetAll and Export. Its
+// placeholders correspond to the arguments returned by filterArgs.
+const auditFilterClause = `
+	WHERE ($1 = '' OR action = $1)
+	AND ($2 = 0 OR target_id = $2)
+	AND ($3::timestamptz IS NULL OR created >= $3)
+	AND ($4::timestamptz IS NULL OR created < $4)`
+
+func (f AuditFilter) filterArgs() []any {
+	return []any{
+		f.Action,
+		f.TargetID,
+		sql.NullTime{Time: f.From, Valid: !f.From.IsZero()},
+		sql.NullTime{Time: f.To, Valid: !f.To.IsZero()},
+	}
+}
+
+// scanAuditEvent scans a row of audit_events columns, in the order selected
+// by GetAll and Export, into an AuditEvent.
+func scanAuditEvent(scan func(dest ...any) error, extra ...any) (AuditEvent, error) {
+	var (
+		e             AuditEvent
+		actorID       sql.NullInt64
+		before, after []byte
+	)
+
+	dest := append(extra, &e.ID, &e.Created, &actorID, &e.Action, &e.TargetType,
+		&e.TargetID, &e.IP, &e.RequestID, &before, &after)
+	err := scan(dest...)
+	if err != nil {
+		return AuditEvent{}, err
+	}
+
+	e.ActorID = int(actorID.Int64)
+	e.Before = before
+	e.After = after
+
+	return e, nil
+}
+
+// GetAll retrieves a page of audit events matching the filter, most recent
+// first, along with pagination metadata.
+func (m *AuditModel) GetAll(ctx context.Context, filter AuditFilter) ([]AuditEvent, Metadata, error) {
+	query := `
+		SELECT count(*) OVER(), id, created, actor_id, action, target_type,
+			target_id, ip, request_id, before, after
+		FROM audit_events` + auditFilterClause + `
+		ORDER BY id DESC
+		LIMIT $5 OFFSET $6`
+
+	args := append(filter.filterArgs(), filter.PageSize, (filter.Page-1)*filter.PageSize)
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var (
+		events       []AuditEvent
+		totalRecords int
+	)
+
+	for rows.Next() {
+		e, err := scanAuditEvent(rows.Scan, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, contextError(ctx, err)
+		}
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+
+	return events, newMetadata(totalRecords, filter.Page, filter.PageSize), nil
+}
+
+// Export calls fn for every audit event matching the filter, oldest first.
+// Events are streamed from the database rather than loaded at once, so that
+// large logs can be exported. For the same reason QueryTimeout isn't applied,
+// and the export is only limited by ctx. If fn returns an error, Export stops
+// and returns it.
+func (m *AuditModel) Export(ctx context.Context, filter AuditFilter, fn func(AuditEvent) error) error {
+	query := `
+		SELECT id, created, actor_id, action, target_type, target_id, ip,
+			request_id, before, after
+		FROM audit_events` + auditFilterClause + `
+		ORDER BY id`
+
+	rows, err := m.DB.QueryContext(ctx, query, filter.filterArgs()...)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEvent(rows.Scan)
+		if err != nil {
+			return contextError(ctx, err)
+		}
+		if err = fn(e); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return contextError(ctx, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// CalendarModel is a wrapper for our sql.DB connection pool.
+// Contains methods for interacting with the calendar_feeds table, which holds
+// the secret token of the iCalendar feed. There is at most one token at a
+// time, and only its hash is stored.
+type CalendarModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type CalendarModelInterface interface {
+	Rotate(ctx context.Context) (string, error)
+	Created(ctx context.Context) (time.Time, error)
+	Authenticate(ctx context.Context, token string) error
+}
+
+// Rotate replaces the feed's token with a new one, in a transaction, so that
+// the previous feed URL stops working. It returns the plaintext token, which
+// should be given to the user and is not stored.
+func (m *CalendarModel) Rotate(ctx context.Context) (string, error) {
+	token, hash, err := newShareToken()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", contextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM calendar_feeds`)
+	if err != nil {
+		return "", contextError(ctx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO calendar_feeds (token_hash) VALUES ($1)`, hash)
+	if err != nil {
+		return "", contextError(ctx, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", contextError(ctx, err)
+	}
+
+	return token, nil
+}
+
+// Created returns the time the current token was created, or the zero time if
+// no token has been created yet.
+func (m *CalendarModel) Created(ctx context.Context) (time.Time, error) {
+	query := `SELECT created FROM calendar_feeds ORDER BY id DESC LIMIT 1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var created time.Time
+	err := m.DB.QueryRowContext(ctx, query).Scan(&created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, contextError(ctx, err)
+	}
+
+	return created, nil
+}
+
+// Authenticate checks the token against the current token. If it doesn't
+// match, a models.ErrNoRecord error is returned.
+func (m *CalendarModel) Authenticate(ctx context.Context, token string) error {
+	hash := sha256.Sum256([]byte(token))
+
+	query := `SELECT EXISTS(SELECT 1 FROM calendar_feeds WHERE token_hash = $1)`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(&exists)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	if !exists {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Company is a struct representing an organization that contacts work for.
+// Domain is the domain of the company's email addresses, if known, and is
+// used to suggest the company for new contacts.
+type Company struct {
+	ID      int
+	Created time.Time
+	Name    string
+	Domain  string
+	Notes   string
+
+	// The number of contacts linked to the company. Only set by GetAll.
+	ContactCount int
+}
+
+// CompanyModel is a wrapper for our sql.DB connection pool.
+// Contains methods for interacting with the companies table.
+type CompanyModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type CompanyModelInterface interface {
+	Get(ctx context.Context, id int) (Company, error)
+	GetAll(ctx context.Context) ([]Company, error)
+	GetByDomain(ctx context.Context, domain string) (Company, error)
+	Search(ctx context.Context, name string, limit int) ([]Company, error)
+	Update(ctx context.Context, company Company) error
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint
+// violation on the named index.
+func isUniqueViolation(err error, index string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == index
+}
+
+// escapeLike escapes the LIKE wildcards in s, so that they are matched
+// literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// isForeignKeyViolation reports whether err is a Postgres foreign key
+// constraint violation, such as a reference to a row that doesn't exist.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// Get retrieves a company by its ID.
+// If no matching company is found, a models.ErrNoRecord error is returned.
+func (m *CompanyModel) Get(ctx context.Context, id int) (Company, error) {
+	query := `
+		SELECT id, created, name, domain, notes
+		FROM companies WHERE id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var c Company
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.Created, &c.Name, &c.Domain, &c.Notes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Company{}, ErrNoRecord
+		}
+		return Company{}, contextError(ctx, err)
+	}
+
+	return c, nil
+}
+
+// GetAll retrieves all companies, ordered by name, with the number of
+// contacts linked to each.
+func (m *CompanyModel) GetAll(ctx context.Context) ([]Company, error) {
+	query := `
+		SELECT co.id, co.created, co.name, co.domain, co.notes, count(c.id)
+		FROM companies co LEFT JOIN contacts c ON c.company_id = co.id
+		GROUP BY co.id
+		ORDER BY lower(co.name)`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var companies []Company
+
+	for rows.Next() {
+		var c Company
+		err = rows.Scan(&c.ID, &c.Created, &c.Name, &c.Domain, &c.Notes, &c.ContactCount)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		companies = append(companies, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return companies, nil
+}
+
+// GetByDomain retrieves the company with the given email domain, ignoring
+// case. If no matching company is found, a models.ErrNoRecord error is
+// returned.
+func (m *CompanyModel) GetByDomain(ctx context.Context, domain string) (Company, error) {
+	if domain == "" {
+		return Company{}, ErrNoRecord
+	}
+
+	query := `
+		SELECT id, created, name, domain, notes
+		FROM companies WHERE lower(domain) = lower($1)`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var c Company
+	err := m.DB.QueryRowContext(ctx, query, domain).Scan(&c.ID, &c.Created, &c.Name, &c.Domain, &c.Notes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Company{}, ErrNoRecord
+		}
+		return Company{}, contextError(ctx, err)
+	}
+
+	return c, nil
+}
+
+// Search retrieves the IDs and names of up to limit companies whose name
+// contains name, ignoring case, ordered by name. If name is empty, the first
+// companies by name are returned.
+func (m *CompanyModel) Search(ctx context.Context, name string, limit int) ([]Company, error) {
+	query := `
+		SELECT id, name FROM companies
+		WHERE name ILIKE '%' || $1 || '%'
+		ORDER BY lower(name)
+		LIMIT $2`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, escapeLike(strings.TrimSpace(name)), limit)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var companies []Company
+
+	for rows.Next() {
+		var c Company
+		err = rows.Scan(&c.ID, &c.Name)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		companies = append(companies, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return companies, nil
+}
+
+// getOrInsertCompany returns the ID of the company with the given name,
+// ignoring case, creating it in tx if it doesn't exist. A new company is given
+// the domain, unless it is empty or already belongs to another company. It is
+// called by ContactModel in the transaction that saves the contact, so that a
+// company isn't left behind if the contact can't be saved.
+//
+// Checking the domain isn't atomic, so a concurrent transaction can take it
+// between the check and the insert. In that case the insert is rolled back to
+// a savepoint, since the error aborts the transaction, and the company is
+// created without a domain instead.
+func getOrInsertCompany(ctx context.Context, tx *sql.Tx, name string, domain string) (int, error) {
+	// The no-op update makes RETURNING include an existing row.
+	query := `
+		INSERT INTO companies (name, domain)
+		SELECT $1, CASE WHEN EXISTS (
+			SELECT 1 FROM companies WHERE lower(domain) = lower($2)
+		) THEN '' ELSE $2 END
+		ON CONFLICT (lower(name)) DO UPDATE SET name = companies.name
+		RETURNING id`
+
+	name = strings.TrimSpace(name)
+
+	var id int
+	if domain == "" {
+		err := tx.QueryRowContext(ctx, query, name, "").Scan(&id)
+		return id, err
+	}
+
+	_, err := tx.ExecContext(ctx, "SAVEPOINT get_or_insert_company")
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRowContext(ctx, query, name, domain).Scan(&id)
+	if isUniqueViolation(err, "companies_domain_idx") {
+		_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT get_or_insert_company")
+		if err != nil {
+			return 0, err
+		}
+		err = tx.QueryRowContext(ctx, query, name, "").Scan(&id)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT get_or_insert_company")
+	return id, err
+}
+
+// Update updates the company's name, domain and notes. If the name or domain
+// belongs to another company, ErrDuplicateCompany is returned. If there is no
+// such company, ErrNoRecord is returned.
+func (m *CompanyModel) Update(ctx context.Context, company Company) error {
+	query := `
+		UPDATE companies SET name = $1, domain = $2, notes = $3
+		WHERE id = $4`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, company.Name, company.Domain, company.Notes, company.ID)
+	if err != nil {
+		if isUniqueViolation(err, "companies_name_idx") || isUniqueViolation(err, "companies_domain_idx") {
+			return ErrDuplicateCompany
+		}
+		return contextError(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
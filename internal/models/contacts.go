@@ -1,74 +1,308 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
-// Contact is a struct representing a contact document.
+// Contact is a struct representing a contact document. The JSON tags are
+// used when a contact is included in an event payload.
+//
+// CompanyID is zero if the contact isn't linked to a company. Company is the
+// linked company's name, which is read from the companies table. Insert and
+// Update ignore CompanyID, and instead link the contact to the company named
+// Company, creating it with the domain CompanyDomain if it doesn't exist yet.
+//
+// Birthday is a date at midnight UTC, or zero if it isn't known.
+//
+// LastContacted is the time of the most recent logged interaction, or zero if
+// there are none, and is maintained by NoteModel.
+//
+// Favorite is set with SetFavorite, and is also ignored by Insert and Update.
 type Contact struct {
-	ID      int
-	First   string
-	Last    string
-	Phone   string
-	Email   string
-	Created time.Time
-	Version int32
+	ID            int       `json:"id"`
+	First         string    `json:"first"`
+	Last          string    `json:"last"`
+	Phone         string    `json:"phone"`
+	Email         string    `json:"email"`
+	Tags          []string  `json:"tags"`
+	CompanyID     int       `json:"company_id,omitempty"`
+	Company       string    `json:"company,omitempty"`
+	CompanyDomain string    `json:"-"`
+	Title         string    `json:"title"`
+	Department    string    `json:"department"`
+	Birthday      time.Time `json:"-"`
+	Favorite      bool      `json:"-"`
+	LastContacted time.Time `json:"-"`
+	Created       time.Time `json:"-"`
+	Version       int32     `json:"version"`
+
+	// The contact's relations. These aren't read by ContactModel, and are only
+	// set where they are needed, such as for vCard exports.
+	Related []Relation `json:"-"`
 }
 
+// contactColumns are the columns read by scanContact, from a contacts table
+// aliased as c. The company name is read with a subquery, rather than a join,
+// so that the same columns can be used in RETURNING clauses.
+const contactColumns = `c.id, c.first, c.last, c.phone, c.email, c.tags, c.company_id,
+	COALESCE((SELECT name FROM companies WHERE id = c.company_id), ''),
+	c.title, c.department, c.birthday, c.last_contacted, c.favorite, c.version`
+
 // ContactModel is a wrapper for our sql.DB connection pool.
 // Contains methods for interacting with the Contacts collection.
+//
+// Each query is run with a deadline of QueryTimeout, in addition to any
+// deadline of the context passed to the method. If QueryTimeout is zero, only
+// the context's own deadline applies.
 type ContactModel struct {
-	DB *sql.DB
+	DB           *sql.DB
+	QueryTimeout time.Duration
 }
 
 type ContactModelInterface interface {
-	Insert(first string, last string, phone string, email string) (int, error)
-	Get(id int) (Contact, error)
-	GetAll() ([]Contact, error)
-	Update(contact *Contact) error
-	Delete(id int) error
+	Insert(ctx context.Context, contact Contact) (int, error)
+	Import(ctx context.Context, contacts []Contact) (ImportResult, error)
+	Get(ctx context.Context, id int) (Contact, error)
+	GetAll(ctx context.Context) ([]Contact, error)
+	GetPage(ctx context.Context, page int, pageSize int, sort string) ([]Contact, Metadata, error)
+	Update(ctx context.Context, contact *Contact) error
+	Delete(ctx context.Context, id int) error
+	GetMany(ctx context.Context, ids []int) ([]Contact, error)
+	DeleteMany(ctx context.Context, contacts []Contact) error
+	AddTagMany(ctx context.Context, contacts []Contact, tag string) error
+	SetFieldMany(ctx context.Context, contacts []Contact, field string, value string) error
+	GetByCompany(ctx context.Context, companyID int) ([]Contact, error)
+	Search(ctx context.Context, name string, limit int) ([]Contact, error)
+	SetFavorite(ctx context.Context, id int, favorite bool) (Contact, error)
+	GetFavorites(ctx context.Context, order string) ([]Contact, error)
+	RecordView(ctx context.Context, id int) error
+	GetRecentlyViewed(ctx context.Context, limit int, order string) ([]Contact, error)
 }
 
-// Insert adds a new contact into the DB.
+// MaxTags is the maximum number of tags a contact may have. Contacts are
+// included in outbox event payloads, so their size must stay bounded.
+const MaxTags = 20
+
+// BulkFields lists the fields that can be set on many contacts at once with
+// SetFieldMany, mapped to their columns. The company is set by name, and
+// SetFieldMany links the contacts to the company with that name.
+var BulkFields = map[string]string{
+	"last":       "last",
+	"company":    "company_id",
+	"phone":      "phone",
+	"title":      "title",
+	"department": "department",
+}
+
+// ContactSorts maps the sort orders accepted by GetPage to ORDER BY clauses.
+// A leading "-" sorts in descending order. Contacts that have never been
+// contacted are sorted as if they were contacted longest ago.
+var ContactSorts = map[string]string{
+	"name":            "first, id",
+	"last_contacted":  "last_contacted ASC NULLS FIRST, id",
+	"-last_contacted": "last_contacted DESC NULLS LAST, id",
+}
+
+// ViewOrders maps the orders accepted by GetFavorites and GetRecentlyViewed to
+// ORDER BY clauses, in terms of the contact_views table aliased as v.
+// Contacts that have never been viewed are sorted last.
+var ViewOrders = map[string]string{
+	"recent":   "v.last_viewed DESC NULLS LAST, c.first, c.id",
+	"frequent": "v.view_count DESC NULLS LAST, v.last_viewed DESC NULLS LAST, c.first, c.id",
+}
+
+// Insert adds a new contact into the DB, and writes a contact.created event
+// to the outbox in the same transaction. The contact's ID, Tags, CompanyID and
+// Version are ignored, and its company is created in the same transaction if
+// it doesn't exist.
 // Returns the ID of the inserted record or an error.
-func (m *ContactModel) Insert(
-	first string, last string, phone string, email string) (int, error) {
+func (m *ContactModel) Insert(ctx context.Context, contact Contact) (int, error) {
+	var id int
+	err := m.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		c, err := insertContact(ctx, tx, contact)
+		id = c.ID
+		return err
+	})
+	return id, err
+}
+
+// insertContact inserts the contact in tx, for Insert and Import, and writes a
+// contact.created event to the outbox. It returns the inserted contact.
+func insertContact(ctx context.Context, tx *sql.Tx, contact Contact) (Contact, error) {
 	query := `
-		INSERT INTO contacts (first, last, phone, email, created)
-		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
-		RETURNING id;`
+		INSERT INTO contacts AS c (first, last, phone, email, company_id, title, department, birthday, created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP)
+		RETURNING ` + contactColumns
+
+	companyID, err := companyIDFor(ctx, tx, contact)
+	if err != nil {
+		return Contact{}, err
+	}
 
-	var lastInsertId int
-	err := m.DB.QueryRow(query, first, last, phone, email).Scan(&lastInsertId)
+	args := []any{contact.First, contact.Last, contact.Phone, contact.Email, companyID,
+		contact.Title, contact.Department,
+		sql.NullTime{Time: contact.Birthday, Valid: !contact.Birthday.IsZero()}}
+
+	c, err := scanContact(tx.QueryRowContext(ctx, query, args...).Scan)
+	if err != nil {
+		return Contact{}, err
+	}
+
+	return c, insertOutboxEvent(ctx, tx, EventContactCreated, c)
+}
+
+// ImportResult contains the contacts and relations added by Import.
+type ImportResult struct {
+	// The inserted contacts, in the order they were given.
+	Contacts []Contact
+
+	// The relations that were added, from the point of view of the contacts
+	// they were added to.
+	Relations []Relation
+
+	// The number of relations that were skipped, because their related name
+	// didn't match exactly one other contact.
+	Unresolved int
+}
+
+// Import inserts the contacts, like Insert, and then adds the relations in
+// their Related fields, all in a single transaction, so that either all of
+// them are imported or none are.
+//
+// Related contacts are given by RelatedName, which must match exactly one
+// contact's full name, ignoring case: one of the imported contacts if any of
+// them have the name, or else an existing contact. Relations are added one
+// way, and relations that already exist are skipped.
+func (m *ContactModel) Import(ctx context.Context, contacts []Contact) (ImportResult, error) {
+	var result ImportResult
+
+	err := m.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		// The IDs of the imported contacts by lower case full name, or 0 if more
+		// than one of them has the name.
+		imported := map[string]int{}
+		ids := make([]int, len(contacts))
+
+		for i, contact := range contacts {
+			c, err := insertContact(ctx, tx, contact)
+			if err != nil {
+				return err
+			}
+
+			name := strings.ToLower(strings.TrimSpace(c.First + " " + c.Last))
+			if _, ok := imported[name]; ok {
+				imported[name] = 0
+			} else {
+				imported[name] = c.ID
+			}
+			ids[i] = c.ID
+			result.Contacts = append(result.Contacts, c)
+		}
+
+		for i, contact := range contacts {
+			for _, rel := range contact.Related {
+				relatedID, err := resolveRelatedName(ctx, tx, rel.RelatedName, imported, ids)
+				if err != nil {
+					return err
+				}
+				if relatedID == 0 || relatedID == ids[i] {
+					result.Unresolved++
+					continue
+				}
+
+				relation := Relation{ContactID: ids[i], RelatedID: relatedID, RelatedName: rel.RelatedName, Type: rel.Type}
+				err = tx.QueryRowContext(ctx, `
+					INSERT INTO contact_relations (contact_id, related_id, type, bidirectional)
+					VALUES ($1, $2, $3, false)
+					ON CONFLICT (contact_id, related_id, type) DO NOTHING
+					RETURNING id, created`,
+					relation.ContactID, relation.RelatedID, relation.Type).Scan(&relation.ID, &relation.Created)
+				if errors.Is(err, sql.ErrNoRows) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				result.Relations = append(result.Relations, relation)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	return result, nil
+}
+
+// resolveRelatedName returns the ID of the contact with the given full name,
+// ignoring case, for Import. The imported contacts, given by lower case name
+// and by ID, are preferred to existing contacts. If no contact or more than
+// one has the name, 0 is returned.
+func resolveRelatedName(ctx context.Context, tx *sql.Tx, name string, imported map[string]int, importedIDs []int) (int, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if id, ok := imported[name]; ok {
+		return id, nil
+	}
+
+	query := `
+		SELECT id FROM contacts
+		WHERE lower(trim(first || ' ' || last)) = $1 AND NOT id = ANY($2)
+		LIMIT 2`
+
+	rows, err := tx.QueryContext(ctx, query, name, pq.Array(importedIDs))
 	if err != nil {
 		return 0, err
 	}
+	defer rows.Close()
 
-	return int(lastInsertId), nil
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) != 1 {
+		return 0, nil
+	}
+	return ids[0], nil
 }
 
 // The Get method retrieves a contact by its ID.
 // If no matching Contact is found, a models.ErrNoRecord error is returned.
-func (m *ContactModel) Get(id int) (Contact, error) {
-	query := `SELECT id, first, last, phone, email, version FROM contacts
-	WHERE id = $1`
+func (m *ContactModel) Get(ctx context.Context, id int) (Contact, error) {
+	query := `SELECT ` + contactColumns + ` FROM contacts c
+	WHERE c.id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
 
 	// Executes a query statement that will return no more than one row.
 	// Accepts the query statement and a variadic list of placeholder values.
-	row := m.DB.QueryRow(query, id)
+	row := m.DB.QueryRowContext(ctx, query, id)
 
 	// Declare an empty Contact and populate it from the row returned by QueryRow.
 	// If no rows were found, an sql.ErrNoRows error is returned.
 	// If multiple rows were found, the first row is used.
-	var s Contact
-	err := row.Scan(&s.ID, &s.First, &s.Last, &s.Phone, &s.Email, &s.Version)
+	s, err := scanContact(row.Scan)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Contact{}, ErrNoRecord
 		} else {
-			return Contact{}, err
+			return Contact{}, contextError(ctx, err)
 		}
 	}
 
@@ -82,16 +316,37 @@ func (m *ContactModel) Get(id int) (Contact, error) {
 // Prevents edit conflicts by verifying that the version of the record in the
 // UPDATE query is the same as the version of the contact argument. In case of
 // an edit conflict, an ErrEditConflict error is returned.
-func (m *ContactModel) Update(contact *Contact) error {
+//
+// A contact.updated event is written to the outbox in the same transaction,
+// in which the contact's company is also created if it doesn't exist.
+func (m *ContactModel) Update(ctx context.Context, contact *Contact) error {
 	query := `
-		UPDATE contacts
-		SET first = $1, last = $2, phone = $3, email = $4, version = version + 1
-		WHERE id = $5 AND version = $6
-		RETURNING version`
+		UPDATE contacts c
+		SET first = $1, last = $2, phone = $3, email = $4, company_id = $5,
+			title = $6, department = $7, birthday = $8, version = version + 1
+		WHERE id = $9 AND version = $10
+		RETURNING ` + contactColumns
 
-	args := []any{contact.First, contact.Last, contact.Phone, contact.Email, contact.ID, contact.Version}
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer tx.Rollback()
 
-	err := m.DB.QueryRow(query, args...).Scan(&contact.Version)
+	companyID, err := companyIDFor(ctx, tx, *contact)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	args := []any{contact.First, contact.Last, contact.Phone, contact.Email, companyID,
+		contact.Title, contact.Department,
+		sql.NullTime{Time: contact.Birthday, Valid: !contact.Birthday.IsZero()},
+		contact.ID, contact.Version}
+
+	updated, err := scanContact(tx.QueryRowContext(ctx, query, args...).Scan)
 	if err != nil {
 		switch {
 		// An sql.ErrNoRows is returned if there are no matching records. Since we
@@ -100,66 +355,516 @@ func (m *ContactModel) Update(contact *Contact) error {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
-			return err
+			return contextError(ctx, err)
 		}
 	}
+
+	*contact = updated
+
+	err = insertOutboxEvent(ctx, tx, EventContactUpdated, *contact)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return contextError(ctx, err)
+	}
+
 	return nil
 }
 
 // GetAll retrieves all contacts from the DB.
-func (m *ContactModel) GetAll() ([]Contact, error) {
-	query := `SELECT id, first, last, phone, email FROM contacts
+func (m *ContactModel) GetAll(ctx context.Context) ([]Contact, error) {
+	query := `SELECT ` + contactColumns + ` FROM contacts c
 	ORDER BY first`
 
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
 	// Query will return an sql.Rows result set containing 10 latest entries.
-	rows, err := m.DB.Query(query)
+	rows, err := m.DB.QueryContext(ctx, query)
 	if err != nil {
-		return nil, err
+		return nil, contextError(ctx, err)
 	}
 	defer rows.Close() // don't defer closing until after handling the error
 
-	// Iterate through result set, calling rows.Scan on each row. Create a contact
-	// Create a contact for each row and add it to the contacts slice.
-	var contacts []Contact
+	contacts, err := scanContacts(rows)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return contacts, nil
+}
+
+// GetPage retrieves a page of contacts in the given sort order, which must be
+// one of ContactSorts, along with pagination metadata. Pages are numbered
+// from 1.
+func (m *ContactModel) GetPage(ctx context.Context, page int, pageSize int, sort string) ([]Contact, Metadata, error) {
+	orderBy, ok := ContactSorts[sort]
+	if !ok {
+		return nil, Metadata{}, fmt.Errorf("unknown sort %q", sort)
+	}
+
+	// The ORDER BY clause is from ContactSorts, so it's safe to include in the
+	// query.
+	query := `
+		SELECT count(*) OVER(), ` + contactColumns + `
+		FROM contacts c
+		ORDER BY ` + orderBy + `
+		LIMIT $1 OFFSET $2`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, Metadata{}, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var (
+		contacts     []Contact
+		totalRecords int
+	)
 
 	for rows.Next() {
-		var s Contact
-		err = rows.Scan(&s.ID, &s.First, &s.Last, &s.Phone, &s.Email)
+		c, err := scanContact(rows.Scan, &totalRecords)
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, contextError(ctx, err)
 		}
-		contacts = append(contacts, s)
+		contacts = append(contacts, c)
 	}
 
-	// rows.Err() contains any errors that occurred during iteration, including
-	// including errors that wouldn't be returned by rows.Scan().
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, Metadata{}, contextError(ctx, err)
 	}
 
-	return contacts, nil
+	return contacts, newMetadata(totalRecords, page, pageSize), nil
 }
 
-func (m *ContactModel) Delete(id int) error {
+// Delete removes the contact with the given ID from the DB, and writes a
+// contact.deleted event containing the deleted contact to the outbox in the
+// same transaction. If there is no such contact, ErrNoRecord is returned.
+func (m *ContactModel) Delete(ctx context.Context, id int) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	query := `DELETE FROM contacts WHERE id = $1`
+	query := `
+		DELETE FROM contacts c WHERE id = $1
+		RETURNING ` + contactColumns
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	c, err := scanContact(tx.QueryRowContext(ctx, query, id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return contextError(ctx, err)
+	}
+
+	err = insertOutboxEvent(ctx, tx, EventContactDeleted, c)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return contextError(ctx, err)
+	}
+
+	return nil
+}
+
+// GetByCompany retrieves the contacts linked to the company with the given
+// ID, ordered by department and then name.
+func (m *ContactModel) GetByCompany(ctx context.Context, companyID int) ([]Contact, error) {
+	query := `
+		SELECT ` + contactColumns + `
+		FROM contacts c
+		WHERE company_id = $1
+		ORDER BY department, first, last, id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, companyID)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	contacts, err := scanContacts(rows)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return contacts, nil
+}
+
+// Search retrieves up to limit contacts whose full name contains name,
+// ignoring case, ordered by name. If name is empty, the first contacts by
+// name are returned.
+func (m *ContactModel) Search(ctx context.Context, name string, limit int) ([]Contact, error) {
+	query := `
+		SELECT ` + contactColumns + `
+		FROM contacts c
+		WHERE c.first || ' ' || c.last ILIKE '%' || $1 || '%'
+		ORDER BY first, last, id
+		LIMIT $2`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
 
-	result, err := m.DB.Exec(query, id)
+	rows, err := m.DB.QueryContext(ctx, query, escapeLike(strings.TrimSpace(name)), limit)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	contacts, err := scanContacts(rows)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return contacts, nil
+}
+
+// SetFavorite marks the contact as a favorite, or unmarks it, and returns the
+// updated contact. A favorite is a preference rather than part of the
+// contact, so the contact's version isn't incremented and no event is
+// written to the outbox.
+// If no matching contact is found, a models.ErrNoRecord error is returned.
+func (m *ContactModel) SetFavorite(ctx context.Context, id int, favorite bool) (Contact, error) {
+	query := `
+		UPDATE contacts c SET favorite = $2
+		WHERE c.id = $1
+		RETURNING ` + contactColumns
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	c, err := scanContact(m.DB.QueryRowContext(ctx, query, id, favorite).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Contact{}, ErrNoRecord
+		}
+		return Contact{}, contextError(ctx, err)
+	}
+
+	return c, nil
+}
+
+// GetFavorites retrieves all favorite contacts, in the given order, which
+// must be a key of ViewOrders.
+func (m *ContactModel) GetFavorites(ctx context.Context, order string) ([]Contact, error) {
+	orderBy, ok := ViewOrders[order]
+	if !ok {
+		return nil, fmt.Errorf("unknown view order %q", order)
+	}
+
+	query := `
+		SELECT ` + contactColumns + `
+		FROM contacts c LEFT JOIN contact_views v ON v.contact_id = c.id
+		WHERE c.favorite
+		ORDER BY ` + orderBy
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	contacts, err := scanContacts(rows)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return contacts, nil
+}
+
+// RecordView records that the contact's page was viewed, incrementing its
+// view count and updating the time it was last viewed.
+func (m *ContactModel) RecordView(ctx context.Context, id int) error {
+	query := `
+		INSERT INTO contact_views (contact_id) VALUES ($1)
+		ON CONFLICT (contact_id) DO UPDATE
+		SET view_count = contact_views.view_count + 1, last_viewed = NOW()`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrNoRecord
+		}
+		return contextError(ctx, err)
+	}
+
+	return nil
+}
+
+// GetRecentlyViewed retrieves up to limit of the contacts whose pages have
+// been viewed, in the given order, which must be a key of ViewOrders. For
+// example, the "frequent" order returns the most viewed contacts.
+func (m *ContactModel) GetRecentlyViewed(ctx context.Context, limit int, order string) ([]Contact, error) {
+	orderBy, ok := ViewOrders[order]
+	if !ok {
+		return nil, fmt.Errorf("unknown view order %q", order)
+	}
+
+	query := `
+		SELECT ` + contactColumns + `
+		FROM contacts c JOIN contact_views v ON v.contact_id = c.id
+		ORDER BY ` + orderBy + `
+		LIMIT $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	contacts, err := scanContacts(rows)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return contacts, nil
+}
+
+// GetMany retrieves the contacts with the given IDs, in the same order as
+// GetAll. IDs with no matching contact are ignored.
+func (m *ContactModel) GetMany(ctx context.Context, ids []int) ([]Contact, error) {
+	query := `
+		SELECT ` + contactColumns + `
+		FROM contacts c
+		WHERE id = ANY($1)
+		ORDER BY first, id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	contacts, err := scanContacts(rows)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return contacts, nil
+}
+
+// DeleteMany deletes the given contacts in a single transaction, writing a
+// contact.deleted event for each to the outbox.
+//
+// Each contact's Version must match its current version. If any contact has
+// changed or been deleted since it was retrieved, nothing is deleted and
+// ErrEditConflict is returned.
+func (m *ContactModel) DeleteMany(ctx context.Context, contacts []Contact) error {
+	query := `
+		DELETE FROM contacts c
+		USING unnest($1::bigint[], $2::integer[]) AS v(id, version)
+		WHERE c.id = v.id AND c.version = v.version
+		RETURNING ` + contactColumns
+
+	return m.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		return changeMany(ctx, tx, contacts, EventContactDeleted, query)
+	})
+}
+
+// AddTagMany adds the tag to each of the given contacts that doesn't already
+// have it, in a single transaction, writing a contact.updated event for each
+// to the outbox. Versions are checked as by DeleteMany, and every contact's
+// version is incremented.
+func (m *ContactModel) AddTagMany(ctx context.Context, contacts []Contact, tag string) error {
+	query := `
+		UPDATE contacts c
+		SET tags = CASE WHEN $3 = ANY(c.tags) THEN c.tags ELSE array_append(c.tags, $3) END,
+			version = c.version + 1
+		FROM unnest($1::bigint[], $2::integer[]) AS v(id, version)
+		WHERE c.id = v.id AND c.version = v.version
+		RETURNING ` + contactColumns
+
+	return m.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		return changeMany(ctx, tx, contacts, EventContactUpdated, query, tag)
+	})
+}
+
+// SetFieldMany sets the field to value for all of the given contacts, in a
+// single transaction, writing a contact.updated event for each to the outbox.
+// The field must be one of BulkFields. For the company field, value is the
+// company's name, and the company is created in the same transaction if it
+// doesn't exist. Versions are checked as by DeleteMany, and every contact's
+// version is incremented.
+func (m *ContactModel) SetFieldMany(ctx context.Context, contacts []Contact, field string, value string) error {
+	column, ok := BulkFields[field]
+	if !ok {
+		return fmt.Errorf("field %q can't be set in bulk", field)
+	}
+
+	// The column name is from BulkFields, so it's safe to include in the query.
+	query := `
+		UPDATE contacts c
+		SET ` + column + ` = $3, version = c.version + 1
+		FROM unnest($1::bigint[], $2::integer[]) AS v(id, version)
+		WHERE c.id = v.id AND c.version = v.version
+		RETURNING ` + contactColumns
+
+	return m.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		var arg any = value
+		if field == "company" {
+			id, err := getOrInsertCompany(ctx, tx, value, "")
+			if err != nil {
+				return err
+			}
+			arg = id
+		}
+		return changeMany(ctx, tx, contacts, EventContactUpdated, query, arg)
+	})
+}
+
+// changeMany runs a query that changes the given contacts, in the transaction
+// tx, and writes an outbox event of eventType for each changed contact. The
+// query's first two parameters are the contacts' IDs and versions, followed by
+// args, and it must return the changed contacts.
+//
+// If fewer contacts are changed than were given, some must have changed or
+// been deleted since they were retrieved, and ErrEditConflict is returned, so
+// that the caller rolls back the transaction.
+func changeMany(ctx context.Context, tx *sql.Tx, contacts []Contact, eventType string, query string, args ...any) error {
+	ids := make([]int, len(contacts))
+	versions := make([]int32, len(contacts))
+	for i, c := range contacts {
+		ids[i] = c.ID
+		versions[i] = c.Version
+	}
+
+	args = append([]any{pq.Array(ids), pq.Array(versions)}, args...)
+
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	changed, err := scanContacts(rows)
+	rows.Close()
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return ErrNoRecord
+	if len(changed) != len(contacts) {
+		return ErrEditConflict
+	}
+
+	for _, c := range changed {
+		err = insertOutboxEvent(ctx, tx, eventType, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inTx calls fn with a new transaction, which is committed if fn returns nil
+// and rolled back otherwise. The transaction and fn share a deadline of
+// QueryTimeout.
+func (m *ContactModel) inTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	// Rollback is a no-op if the transaction has been committed.
+	defer tx.Rollback()
+
+	err = fn(ctx, tx)
+	if err != nil {
+		if errors.Is(err, ErrEditConflict) {
+			return err
+		}
+		return contextError(ctx, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return contextError(ctx, err)
 	}
 
 	return nil
 }
+
+// companyIDFor returns the ID of the company named by contact.Company, for
+// Insert and Update, creating it in tx with the domain contact.CompanyDomain if
+// it doesn't exist. If the contact has no company, a null ID is returned.
+func companyIDFor(ctx context.Context, tx *sql.Tx, contact Contact) (sql.NullInt64, error) {
+	if contact.Company == "" {
+		return sql.NullInt64{}, nil
+	}
+
+	id, err := getOrInsertCompany(ctx, tx, contact.Company, contact.CompanyDomain)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	return sql.NullInt64{Int64: int64(id), Valid: true}, nil
+}
+
+// scanContact scans contactColumns into a Contact. Any extra destinations
+// are scanned first, for columns that precede contactColumns in the query.
+func scanContact(scan func(dest ...any) error, extra ...any) (Contact, error) {
+	var (
+		c             Contact
+		companyID     sql.NullInt64
+		birthday      sql.NullTime
+		lastContacted sql.NullTime
+	)
+
+	dest := append(extra, &c.ID, &c.First, &c.Last, &c.Phone, &c.Email, pq.Array(&c.Tags),
+		&companyID, &c.Company, &c.Title, &c.Department, &birthday, &lastContacted, &c.Favorite, &c.Version)
+	err := scan(dest...)
+	if err != nil {
+		return Contact{}, err
+	}
+
+	c.CompanyID = int(companyID.Int64)
+	c.Birthday = birthday.Time
+	c.LastContacted = lastContacted.Time
+
+	return c, nil
+}
+
+// scanContacts reads all of the rows, which must contain contactColumns, into
+// a slice of contacts. It doesn't close rows.
+func scanContacts(rows *sql.Rows) ([]Contact, error) {
+	var contacts []Contact
+
+	for rows.Next() {
+		c, err := scanContact(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, c)
+	}
+
+	return contacts, rows.Err()
+}
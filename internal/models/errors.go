@@ -10,3 +10,9 @@ var ErrDuplicateEmail = errors.New("models: duplicate email")
 
 // Occurs when login credentials are invalid.
 var ErrInvalidCredentials = errors.New("models: invalid credentials")
+
+// Occurs when a company with the given name or domain already exists.
+var ErrDuplicateCompany = errors.New("models: duplicate company")
+
+// Occurs when two contacts are already related with the given type.
+var ErrDuplicateRelation = errors.New("models: duplicate relation")
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// HealthModel is a wrapper for our sql.DB connection pool. It contains methods
+// used to check the health of the database.
+type HealthModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type HealthModelInterface interface {
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (version int, dirty bool, err error)
+}
+
+// Ping verifies that a connection to the database can be established.
+func (m *HealthModel) Ping(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	return contextError(ctx, m.DB.PingContext(ctx))
+}
+
+// SchemaVersion returns the version of the most recently applied migration,
+// as recorded by the migrate CLI in the schema_migrations table. If dirty is
+// true, the last migration failed part way through.
+//
+// If no migrations have been applied, the version is 0.
+func (m *HealthModel) SchemaVersion(ctx context.Context) (int, bool, error) {
+	query := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var (
+		version int
+		dirty   bool
+	)
+	err := m.DB.QueryRowContext(ctx, query).Scan(&version, &dirty)
+	if err != nil {
+		// The table doesn't exist until the first migration is run.
+		var pqErr *pq.Error
+		if errors.Is(err, sql.ErrNoRows) ||
+			(errors.As(err, &pqErr) && pqErr.Code.Name() == "undefined_table") {
+			return 0, false, nil
+		}
+		return 0, false, contextError(ctx, err)
+	}
+
+	return version, dirty, nil
+}
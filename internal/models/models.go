@@ -1,8 +1,10 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 var (
@@ -18,12 +20,56 @@ var (
 
 // Models is a struct that wraps all of our models.
 type Models struct {
-	Contacts ContactModel
+	Contacts      ContactModel
+	Sessions      SessionModel
+	Health        HealthModel
+	Shares        ShareModel
+	Audit         AuditModel
+	Webhooks      WebhookModel
+	Companies     CompanyModel
+	Relations     RelationModel
+	Notes         NoteModel
+	Reminders     ReminderModel
+	Notifications NotificationModel
+	Calendar      CalendarModel
 }
 
-// NewModels returns an empty instance of our Model struct.
-func NewModels(db *sql.DB) Models {
+// NewModels returns an empty instance of our Model struct. Each model's
+// queries will time out after queryTimeout.
+func NewModels(db *sql.DB, queryTimeout time.Duration) Models {
 	return Models{
-		Contacts: ContactModel{DB: db},
+		Contacts:      ContactModel{DB: db, QueryTimeout: queryTimeout},
+		Sessions:      SessionModel{DB: db, QueryTimeout: queryTimeout},
+		Health:        HealthModel{DB: db, QueryTimeout: queryTimeout},
+		Shares:        ShareModel{DB: db, QueryTimeout: queryTimeout},
+		Audit:         AuditModel{DB: db, QueryTimeout: queryTimeout},
+		Webhooks:      WebhookModel{DB: db, QueryTimeout: queryTimeout},
+		Companies:     CompanyModel{DB: db, QueryTimeout: queryTimeout},
+		Relations:     RelationModel{DB: db, QueryTimeout: queryTimeout},
+		Notes:         NoteModel{DB: db, QueryTimeout: queryTimeout},
+		Reminders:     ReminderModel{DB: db, QueryTimeout: queryTimeout},
+		Notifications: NotificationModel{DB: db, QueryTimeout: queryTimeout},
+		Calendar:      CalendarModel{DB: db, QueryTimeout: queryTimeout},
 	}
 }
+
+// withTimeout returns a copy of ctx with the given timeout applied. If timeout
+// is zero, ctx is returned unchanged, along with a no-op cancel function.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// contextError returns the context's error if it has been cancelled or its
+// deadline has been exceeded. lib/pq reports a cancelled query as a generic
+// "canceling statement" error, so this lets callers check for
+// context.DeadlineExceeded or context.Canceled with errors.Is. If the context
+// is still active, err is returned unchanged.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
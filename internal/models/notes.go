@@ -0,0 +1,210 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+)
+
+// Kinds of note. A note of any kind other than NoteKindNote records an
+// interaction with the contact, and updates the contact's LastContacted time.
+const (
+	NoteKindNote    = "note"
+	NoteKindCall    = "call"
+	NoteKindMeeting = "meeting"
+	NoteKindEmail   = "email"
+)
+
+// NoteKinds lists all of the kinds of note, in the order they are offered in
+// forms.
+var NoteKinds = []string{NoteKindNote, NoteKindCall, NoteKindMeeting, NoteKindEmail}
+
+// Note is a struct representing a note about a contact, or a logged
+// interaction with them. Body is Markdown. Occurred is when the interaction
+// took place, which may be earlier than Created.
+type Note struct {
+	ID        int
+	Created   time.Time
+	Occurred  time.Time
+	ContactID int
+	Kind      string
+	Author    string
+	Body      string
+}
+
+// IsInteraction returns true if the note records an interaction with the
+// contact.
+func (n Note) IsInteraction() bool {
+	return n.Kind != NoteKindNote
+}
+
+// NoteModel is a wrapper for our sql.DB connection pool.
+// Contains methods for interacting with the contact_notes table.
+type NoteModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type NoteModelInterface interface {
+	Insert(ctx context.Context, note Note) (int, error)
+	Get(ctx context.Context, id int) (Note, error)
+	GetForContact(ctx context.Context, contactID int, limit int) ([]Note, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// updateLastContacted sets the contact's last_contacted time to that of their
+// most recent interaction. It doesn't change the contact's version, since it
+// isn't an edit of the contact.
+const updateLastContacted = `
+	UPDATE contacts SET last_contacted = (
+		SELECT max(occurred) FROM contact_notes
+		WHERE contact_id = $1 AND kind <> 'note'
+	)
+	WHERE id = $1`
+
+// Insert adds a note, in a transaction that updates the contact's
+// LastContacted time if the note is an interaction. If Occurred is zero, the
+// current time is used.
+// Returns the ID of the inserted record or an error. If the contact doesn't
+// exist, ErrNoRecord is returned.
+func (m *NoteModel) Insert(ctx context.Context, note Note) (int, error) {
+	if !slices.Contains(NoteKinds, note.Kind) {
+		return 0, errors.New("models: unknown note kind")
+	}
+	if note.Occurred.IsZero() {
+		note.Occurred = time.Now()
+	}
+
+	query := `
+		INSERT INTO contact_notes (occurred, contact_id, kind, author, body)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, contextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, query, note.Occurred, note.ContactID, note.Kind, note.Author, note.Body).Scan(&id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return 0, ErrNoRecord
+		}
+		return 0, contextError(ctx, err)
+	}
+
+	if note.IsInteraction() {
+		_, err = tx.ExecContext(ctx, updateLastContacted, note.ContactID)
+		if err != nil {
+			return 0, contextError(ctx, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, contextError(ctx, err)
+	}
+
+	return id, nil
+}
+
+// Get retrieves a note by its ID.
+// If no matching note is found, a models.ErrNoRecord error is returned.
+func (m *NoteModel) Get(ctx context.Context, id int) (Note, error) {
+	query := `
+		SELECT id, created, occurred, contact_id, kind, author, body
+		FROM contact_notes WHERE id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var n Note
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&n.ID, &n.Created, &n.Occurred, &n.ContactID, &n.Kind, &n.Author, &n.Body)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Note{}, ErrNoRecord
+		}
+		return Note{}, contextError(ctx, err)
+	}
+
+	return n, nil
+}
+
+// GetForContact retrieves the contact's most recent notes, up to limit, most
+// recently occurred first.
+func (m *NoteModel) GetForContact(ctx context.Context, contactID int, limit int) ([]Note, error) {
+	query := `
+		SELECT id, created, occurred, contact_id, kind, author, body
+		FROM contact_notes
+		WHERE contact_id = $1
+		ORDER BY occurred DESC, id DESC
+		LIMIT $2`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, contactID, limit)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var notes []Note
+
+	for rows.Next() {
+		var n Note
+		err = rows.Scan(&n.ID, &n.Created, &n.Occurred, &n.ContactID, &n.Kind, &n.Author, &n.Body)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		notes = append(notes, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return notes, nil
+}
+
+// Delete removes a note, in a transaction that recalculates the contact's
+// LastContacted time.
+// If no matching note is found, a models.ErrNoRecord error is returned.
+func (m *NoteModel) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM contact_notes WHERE id = $1 RETURNING contact_id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	var contactID int
+	err = tx.QueryRowContext(ctx, query, id).Scan(&contactID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return contextError(ctx, err)
+	}
+
+	_, err = tx.ExecContext(ctx, updateLastContacted, contactID)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return contextError(ctx, err)
+	}
+
+	return nil
+}
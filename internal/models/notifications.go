@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Notification is a struct representing an in-app notification, such as a
+// fired reminder. ReminderID is 0 if the reminder has since been deleted. Read
+// is zero if the notification hasn't been read.
+type Notification struct {
+	ID          int
+	Created     time.Time
+	ContactID   int
+	ContactName string
+	ReminderID  int
+	Message     string
+	Read        time.Time
+}
+
+// NotificationModel is a wrapper for our sql.DB connection pool.
+// Contains methods for interacting with the notifications table. Notifications
+// are added by ReminderModel.FireDue.
+type NotificationModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type NotificationModelInterface interface {
+	GetRecent(ctx context.Context, limit int) ([]Notification, error)
+	MarkAllRead(ctx context.Context) error
+}
+
+// GetRecent retrieves the most recent notifications, up to limit, newest
+// first.
+func (m *NotificationModel) GetRecent(ctx context.Context, limit int) ([]Notification, error) {
+	query := `
+		SELECT n.id, n.created, n.contact_id,
+			COALESCE((SELECT first || ' ' || last FROM contacts WHERE id = n.contact_id), ''),
+			n.reminder_id, n.message, n.read
+		FROM notifications n
+		ORDER BY n.created DESC, n.id DESC
+		LIMIT $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+
+	for rows.Next() {
+		var (
+			n          Notification
+			reminderID sql.NullInt64
+			read       sql.NullTime
+		)
+		err = rows.Scan(&n.ID, &n.Created, &n.ContactID, &n.ContactName, &reminderID, &n.Message, &read)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		n.ReminderID = int(reminderID.Int64)
+		n.Read = read.Time
+		notifications = append(notifications, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return notifications, nil
+}
+
+// MarkAllRead marks all unread notifications as read.
+func (m *NotificationModel) MarkAllRead(ctx context.Context) error {
+	query := `UPDATE notifications SET read = NOW() WHERE read IS NULL`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	return nil
+}
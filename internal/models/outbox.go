@@ -0,0 +1,50 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Contact change events. These are written to the outbox_events table and
+// delivered to webhook subscribers.
+const (
+	EventContactCreated = "contact.created"
+	EventContactUpdated = "contact.updated"
+	EventContactDeleted = "contact.deleted"
+)
+
+// EventTypes lists all of the event types that can be subscribed to.
+var EventTypes = []string{
+	EventContactCreated,
+	EventContactUpdated,
+	EventContactDeleted,
+}
+
+// EventPayload is the JSON payload of an outbox event, which is sent as the
+// body of webhook requests.
+type EventPayload struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Contact    Contact   `json:"contact"`
+}
+
+// insertOutboxEvent writes a contact change event to the outbox. It must be
+// called with the transaction that makes the change, so that the event is
+// recorded if and only if the change is committed.
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, eventType string, contact Contact) error {
+	payload, err := json.Marshal(EventPayload{
+		Event:      eventType,
+		OccurredAt: time.Now().UTC(),
+		Contact:    contact,
+	})
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO outbox_events (event_type, payload) VALUES ($1, $2)`
+
+	_, err = tx.ExecContext(ctx, query, eventType, string(payload))
+	return err
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RelationType is a kind of relationship between two contacts. Label
+// describes the related contact, as in "Manager: Jane Doe", and Inverse is
+// the type of the same relation seen from the related contact.
+type RelationType struct {
+	Name    string
+	Label   string
+	Inverse string
+}
+
+// RelationTypes lists the types of relation that can be recorded, in the
+// order they are offered in forms.
+var RelationTypes = []RelationType{
+	{Name: "spouse", Label: "Spouse", Inverse: "spouse"},
+	{Name: "manager", Label: "Manager", Inverse: "report"},
+	{Name: "report", Label: "Direct report", Inverse: "manager"},
+	{Name: "assistant", Label: "Assistant", Inverse: "executive"},
+	{Name: "executive", Label: "Assists", Inverse: "assistant"},
+	{Name: "referrer", Label: "Referred by", Inverse: "referral"},
+	{Name: "referral", Label: "Referred", Inverse: "referrer"},
+}
+
+// relationType returns the RelationType with the given name.
+func relationType(name string) (RelationType, bool) {
+	for _, t := range RelationTypes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return RelationType{}, false
+}
+
+// Relation is a struct representing a relationship between two contacts,
+// from the point of view of the contact with ContactID: the related contact
+// is that contact's Type.
+//
+// A relation is stored once, for the contact it was added to. If it is
+// bidirectional it is also read for the related contact, with Inverse set and
+// Type replaced by its inverse, so ContactID and RelatedID are swapped.
+type Relation struct {
+	ID            int
+	Created       time.Time
+	ContactID     int
+	RelatedID     int
+	RelatedName   string
+	Type          string
+	Bidirectional bool
+	Inverse       bool
+}
+
+// Label returns the label of the relation's type, or the type itself if it is
+// unknown.
+func (r Relation) Label() string {
+	if t, ok := relationType(r.Type); ok {
+		return t.Label
+	}
+	return r.Type
+}
+
+// RelationModel is a wrapper for our sql.DB connection pool.
+// Contains methods for interacting with the contact_relations table.
+type RelationModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type RelationModelInterface interface {
+	Insert(ctx context.Context, contactID int, relatedID int, relType string, bidirectional bool) (int, error)
+	Get(ctx context.Context, id int) (Relation, error)
+	GetForContact(ctx context.Context, contactID int) ([]Relation, error)
+	GetForContacts(ctx context.Context, contactIDs []int) (map[int][]Relation, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// Insert records that the related contact is the contact's relType.
+// Returns the ID of the inserted record or an error. If the relation already
+// exists, ErrDuplicateRelation is returned, and if either contact doesn't
+// exist, ErrNoRecord is returned.
+func (m *RelationModel) Insert(ctx context.Context, contactID int, relatedID int, relType string, bidirectional bool) (int, error) {
+	query := `
+		INSERT INTO contact_relations (contact_id, related_id, type, bidirectional)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var id int
+	err := m.DB.QueryRowContext(ctx, query, contactID, relatedID, relType, bidirectional).Scan(&id)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "contact_relations_contact_id_related_id_type_key"):
+			return 0, ErrDuplicateRelation
+		case isForeignKeyViolation(err):
+			return 0, ErrNoRecord
+		default:
+			return 0, contextError(ctx, err)
+		}
+	}
+
+	return id, nil
+}
+
+// Get retrieves a relation by its ID, from the point of view of the contact it
+// was added to.
+// If no matching relation is found, a models.ErrNoRecord error is returned.
+func (m *RelationModel) Get(ctx context.Context, id int) (Relation, error) {
+	query := `
+		SELECT r.id, r.created, r.contact_id, r.related_id, c.first || ' ' || c.last,
+			r.type, r.bidirectional, false
+		FROM contact_relations r JOIN contacts c ON c.id = r.related_id
+		WHERE r.id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rel, err := scanRelation(m.DB.QueryRowContext(ctx, query, id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Relation{}, ErrNoRecord
+		}
+		return Relation{}, contextError(ctx, err)
+	}
+
+	return rel, nil
+}
+
+// GetForContact retrieves the contact's relations, including bidirectional
+// relations added to other contacts, ordered by the related contact's name.
+func (m *RelationModel) GetForContact(ctx context.Context, contactID int) ([]Relation, error) {
+	relations, err := m.GetForContacts(ctx, []int{contactID})
+	if err != nil {
+		return nil, err
+	}
+	return relations[contactID], nil
+}
+
+// GetForContacts retrieves the relations of each of the contacts, as by
+// GetForContact, keyed by contact ID.
+func (m *RelationModel) GetForContacts(ctx context.Context, contactIDs []int) (map[int][]Relation, error) {
+	query := `
+		SELECT r.id, r.created, r.contact_id, r.related_id, c.first || ' ' || c.last,
+			r.type, r.bidirectional, false
+		FROM contact_relations r JOIN contacts c ON c.id = r.related_id
+		WHERE r.contact_id = ANY($1)
+		UNION ALL
+		SELECT r.id, r.created, r.related_id, r.contact_id, c.first || ' ' || c.last,
+			r.type, r.bidirectional, true
+		FROM contact_relations r JOIN contacts c ON c.id = r.contact_id
+		WHERE r.related_id = ANY($1) AND r.bidirectional
+		ORDER BY 5, 1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(contactIDs))
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	relations := make(map[int][]Relation)
+
+	for rows.Next() {
+		rel, err := scanRelation(rows.Scan)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		relations[rel.ContactID] = append(relations[rel.ContactID], rel)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return relations, nil
+}
+
+// scanRelation scans a relation, replacing the type of an inverse relation
+// with its inverse.
+func scanRelation(scan func(dest ...any) error) (Relation, error) {
+	var r Relation
+	err := scan(&r.ID, &r.Created, &r.ContactID, &r.RelatedID, &r.RelatedName,
+		&r.Type, &r.Bidirectional, &r.Inverse)
+	if err != nil {
+		return Relation{}, err
+	}
+
+	if t, ok := relationType(r.Type); ok && r.Inverse {
+		r.Type = t.Inverse
+	}
+
+	return r, nil
+}
+
+// Delete removes a relation, from both of the contacts it relates.
+// If no matching relation is found, a models.ErrNoRecord error is returned.
+func (m *RelationModel) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM contact_relations WHERE id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
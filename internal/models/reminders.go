@@ -0,0 +1,307 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Reminder is a struct representing a follow-up reminder for a contact.
+// Recurrence is an RRULE such as "FREQ=WEEKLY;INTERVAL=2" (see
+// reminders.ParseRule), or empty for a reminder that doesn't repeat. Fired is
+// the time the notification for the current Due time was sent, or zero if it
+// hasn't been sent yet.
+type Reminder struct {
+	ID          int
+	Created     time.Time
+	ContactID   int
+	ContactName string
+	Due         time.Time
+	Note        string
+	Recurrence  string
+	Fired       time.Time
+	Done        bool
+}
+
+// Message returns the text of the reminder's notification.
+func (r Reminder) Message() string {
+	if r.Note == "" {
+		return "Follow up with " + r.ContactName
+	}
+	return r.Note
+}
+
+// ReminderModel is a wrapper for our sql.DB connection pool.
+// Contains methods for interacting with the reminders table.
+type ReminderModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type ReminderModelInterface interface {
+	Insert(ctx context.Context, reminder Reminder) (int, error)
+	Get(ctx context.Context, id int) (Reminder, error)
+	GetForContact(ctx context.Context, contactID int) ([]Reminder, error)
+	GetDue(ctx context.Context, before time.Time) ([]Reminder, error)
+	GetOpen(ctx context.Context) ([]Reminder, error)
+	Complete(ctx context.Context, id int, next time.Time) error
+	Delete(ctx context.Context, id int) error
+	FireDue(ctx context.Context, limit int) ([]Reminder, error)
+}
+
+// reminderColumns are the columns selected by the ReminderModel's queries, in
+// the order expected by scanReminder. Queries must alias reminders as r.
+const reminderColumns = `r.id, r.created, r.contact_id,
+	COALESCE((SELECT first || ' ' || last FROM contacts WHERE id = r.contact_id), ''),
+	r.due, r.note, r.recurrence, r.fired, r.done`
+
+// scanReminder scans a row of reminderColumns into a Reminder.
+func scanReminder(scan func(dest ...any) error) (Reminder, error) {
+	var (
+		r     Reminder
+		fired sql.NullTime
+	)
+
+	err := scan(&r.ID, &r.Created, &r.ContactID, &r.ContactName, &r.Due, &r.Note,
+		&r.Recurrence, &fired, &r.Done)
+	if err != nil {
+		return Reminder{}, err
+	}
+	r.Fired = fired.Time
+
+	return r, nil
+}
+
+// scanReminders scans all rows of reminderColumns, and closes rows.
+func scanReminders(ctx context.Context, rows *sql.Rows) ([]Reminder, error) {
+	defer rows.Close()
+
+	var reminders []Reminder
+
+	for rows.Next() {
+		r, err := scanReminder(rows.Scan)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		reminders = append(reminders, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return reminders, nil
+}
+
+// Insert adds a reminder. Returns the ID of the inserted record or an error.
+// If the contact doesn't exist, ErrNoRecord is returned.
+func (m *ReminderModel) Insert(ctx context.Context, reminder Reminder) (int, error) {
+	query := `
+		INSERT INTO reminders (contact_id, due, note, recurrence)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var id int
+	err := m.DB.QueryRowContext(ctx, query, reminder.ContactID, reminder.Due, reminder.Note,
+		reminder.Recurrence).Scan(&id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return 0, ErrNoRecord
+		}
+		return 0, contextError(ctx, err)
+	}
+
+	return id, nil
+}
+
+// Get retrieves a reminder by its ID.
+// If no matching reminder is found, a models.ErrNoRecord error is returned.
+func (m *ReminderModel) Get(ctx context.Context, id int) (Reminder, error) {
+	query := `SELECT ` + reminderColumns + ` FROM reminders r WHERE r.id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	r, err := scanReminder(m.DB.QueryRowContext(ctx, query, id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Reminder{}, ErrNoRecord
+		}
+		return Reminder{}, contextError(ctx, err)
+	}
+
+	return r, nil
+}
+
+// GetForContact retrieves the contact's open reminders, soonest first.
+func (m *ReminderModel) GetForContact(ctx context.Context, contactID int) ([]Reminder, error) {
+	query := `
+		SELECT ` + reminderColumns + ` FROM reminders r
+		WHERE r.contact_id = $1 AND NOT r.done
+		ORDER BY r.due, r.id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, contactID)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return scanReminders(ctx, rows)
+}
+
+// GetDue retrieves all open reminders that are due before the given time,
+// including overdue ones, soonest first.
+func (m *ReminderModel) GetDue(ctx context.Context, before time.Time) ([]Reminder, error) {
+	query := `
+		SELECT ` + reminderColumns + ` FROM reminders r
+		WHERE NOT r.done AND r.due < $1
+		ORDER BY r.due, r.id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return scanReminders(ctx, rows)
+}
+
+// GetOpen retrieves all open reminders, soonest first.
+func (m *ReminderModel) GetOpen(ctx context.Context) ([]Reminder, error) {
+	query := `
+		SELECT ` + reminderColumns + ` FROM reminders r
+		WHERE NOT r.done
+		ORDER BY r.due, r.id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return scanReminders(ctx, rows)
+}
+
+// Complete marks a reminder as done. If next is not zero, the reminder is
+// instead moved to next, its next occurrence, and will fire again then.
+// If no matching reminder is found, a models.ErrNoRecord error is returned.
+func (m *ReminderModel) Complete(ctx context.Context, id int, next time.Time) error {
+	query := `UPDATE reminders SET done = true WHERE id = $1`
+	args := []any{id}
+	if !next.IsZero() {
+		query = `UPDATE reminders SET due = $2, fired = NULL WHERE id = $1`
+		args = append(args, next)
+	}
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Delete removes a reminder.
+// If no matching reminder is found, a models.ErrNoRecord error is returned.
+func (m *ReminderModel) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM reminders WHERE id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// FireDue finds up to limit open reminders that are due and haven't fired,
+// and in a single transaction adds a notification for each and marks them as
+// fired. It returns the fired reminders, so that they can also be delivered
+// by other means.
+//
+// Reminders are locked with FOR UPDATE SKIP LOCKED, so multiple instances of
+// the application can run FireDue concurrently without firing a reminder
+// twice.
+func (m *ReminderModel) FireDue(ctx context.Context, limit int) ([]Reminder, error) {
+	query := `
+		SELECT ` + reminderColumns + ` FROM reminders r
+		WHERE NOT r.done AND r.fired IS NULL AND r.due <= NOW()
+		ORDER BY r.due
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	reminders, err := scanReminders(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, r := range reminders {
+		query := `
+			INSERT INTO notifications (contact_id, reminder_id, message)
+			VALUES ($1, $2, $3)`
+
+		_, err = tx.ExecContext(ctx, query, r.ContactID, r.ID, r.Message())
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+
+		query = `UPDATE reminders SET fired = NOW() WHERE id = $1 RETURNING fired`
+
+		err = tx.QueryRowContext(ctx, query, r.ID).Scan(&reminders[i].Fired)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return reminders, nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SessionModel is a wrapper for our sql.DB connection pool. The sessions table
+// itself is managed by scs/postgresstore, so SessionModel only contains
+// read-only methods for reporting on it.
+type SessionModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type SessionModelInterface interface {
+	CountActive(ctx context.Context) (int, error)
+}
+
+// CountActive returns the number of sessions in the sessions table that have
+// not yet expired. Expired sessions are only removed periodically by
+// postgresstore's cleanup goroutine, so they are excluded explicitly.
+func (m *SessionModel) CountActive(ctx context.Context) (int, error) {
+	query := `SELECT count(*) FROM sessions WHERE expiry > CURRENT_TIMESTAMP`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query).Scan(&count)
+	if err != nil {
+		return 0, contextError(ctx, err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+)
+
+// Share is a struct representing a public link to a single contact. Only a
+// hash of the link's token is stored, so the token itself can't be recovered
+// after the share is created.
+type Share struct {
+	ID           int
+	ContactID    int
+	ContactName  string
+	Created      time.Time
+	Expires      time.Time
+	AccessCount  int
+	LastAccessed time.Time
+}
+
+// ShareModel is a wrapper for our sql.DB connection pool.
+// Contains methods for interacting with the contact_shares table.
+type ShareModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type ShareModelInterface interface {
+	Insert(ctx context.Context, contactID int, ttl time.Duration) (int, string, error)
+	GetContact(ctx context.Context, token string) (Contact, error)
+	GetAllActive(ctx context.Context) ([]Share, error)
+	Revoke(ctx context.Context, id int) error
+}
+
+// newShareToken returns a random token for a share link or calendar feed,
+// along with its SHA-256 hash. The token contains 128 bits of randomness,
+// encoded as 26 base32 characters so that it is safe to use in a URL.
+func newShareToken() (string, []byte, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	hash := sha256.Sum256([]byte(token))
+
+	return token, hash[:], nil
+}
+
+// Insert creates a share link for the contact that expires after ttl. It
+// returns the ID of the share and the plaintext token, which should be given
+// to the user and is not stored.
+func (m *ShareModel) Insert(ctx context.Context, contactID int, ttl time.Duration) (int, string, error) {
+	token, hash, err := newShareToken()
+	if err != nil {
+		return 0, "", err
+	}
+
+	query := `
+		INSERT INTO contact_shares (contact_id, token_hash, expires)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var id int
+	err = m.DB.QueryRowContext(ctx, query, contactID, hash, time.Now().Add(ttl)).Scan(&id)
+	if err != nil {
+		return 0, "", contextError(ctx, err)
+	}
+
+	return id, token, nil
+}
+
+// GetContact retrieves the contact shared by the link with the given token,
+// and increments the link's access count. If the token doesn't match a share
+// that is unexpired and unrevoked, a models.ErrNoRecord error is returned.
+func (m *ShareModel) GetContact(ctx context.Context, token string) (Contact, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	query := `
+		WITH share AS (
+			UPDATE contact_shares
+			SET access_count = access_count + 1, last_accessed = NOW()
+			WHERE token_hash = $1 AND NOT revoked AND expires > NOW()
+			RETURNING contact_id
+		)
+		SELECT c.id, c.first, c.last, c.phone, c.email
+		FROM contacts c JOIN share ON c.id = share.contact_id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var c Contact
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(&c.ID, &c.First, &c.Last, &c.Phone, &c.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Contact{}, ErrNoRecord
+		}
+		return Contact{}, contextError(ctx, err)
+	}
+
+	return c, nil
+}
+
+// GetAllActive retrieves all shares that are unexpired and unrevoked, soonest
+// to expire first.
+func (m *ShareModel) GetAllActive(ctx context.Context) ([]Share, error) {
+	query := `
+		SELECT s.id, s.contact_id, c.first || ' ' || c.last, s.created, s.expires,
+			s.access_count, s.last_accessed
+		FROM contact_shares s JOIN contacts c ON c.id = s.contact_id
+		WHERE NOT s.revoked AND s.expires > NOW()
+		ORDER BY s.expires`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var shares []Share
+
+	for rows.Next() {
+		var s Share
+		var lastAccessed sql.NullTime
+		err = rows.Scan(&s.ID, &s.ContactID, &s.ContactName, &s.Created, &s.Expires,
+			&s.AccessCount, &lastAccessed)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		s.LastAccessed = lastAccessed.Time
+		shares = append(shares, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return shares, nil
+}
+
+// Revoke revokes the share with the given ID, so that its link no longer
+// works. If there is no such share, a models.ErrNoRecord error is returned.
+func (m *ShareModel) Revoke(ctx context.Context, id int) error {
+	query := `UPDATE contact_shares SET revoked = true WHERE id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,390 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Statuses of a webhook delivery.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookSubscription is a struct representing a URL that is sent contact
+// change events of the subscribed types. Requests are signed with Secret.
+type WebhookSubscription struct {
+	ID         int
+	Created    time.Time
+	URL        string
+	Secret     string
+	EventTypes []string
+}
+
+// WebhookDelivery is a struct representing the delivery of a single event to
+// a single subscription, including its retries. ResponseCode and Error are
+// from the most recent attempt.
+type WebhookDelivery struct {
+	ID             int
+	Created        time.Time
+	SubscriptionID int
+	EventID        int
+	EventType      string
+	Status         string
+	Attempts       int
+	NextAttempt    time.Time
+	ResponseCode   int
+	Error          string
+
+	// The following fields are only set by ClaimDeliveries, and contain what's
+	// needed to make the request.
+	URL     string
+	Secret  string
+	Payload []byte
+}
+
+// WebhookModel is a wrapper for our sql.DB connection pool.
+// Contains methods for managing webhook subscriptions and deliveries, and for
+// processing the outbox_events table.
+type WebhookModel struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+}
+
+type WebhookModelInterface interface {
+	Insert(ctx context.Context, url string, secret string, eventTypes []string) (int, error)
+	Get(ctx context.Context, id int) (WebhookSubscription, error)
+	GetAll(ctx context.Context) ([]WebhookSubscription, error)
+	Delete(ctx context.Context, id int) error
+	GetDeliveries(ctx context.Context, subscriptionID int, limit int) ([]WebhookDelivery, error)
+	FanOut(ctx context.Context, limit int) (int, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, d WebhookDelivery) error
+	GetEventPayload(ctx context.Context, id int) ([]byte, error)
+	Prune(ctx context.Context, before time.Time) (int, int, error)
+}
+
+// Insert adds a new webhook subscription.
+// Returns the ID of the inserted record or an error.
+func (m *WebhookModel) Insert(ctx context.Context, url string, secret string, eventTypes []string) (int, error) {
+	query := `
+		INSERT INTO webhook_subscriptions (url, secret, event_types)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var id int
+	err := m.DB.QueryRowContext(ctx, query, url, secret, pq.Array(eventTypes)).Scan(&id)
+	if err != nil {
+		return 0, contextError(ctx, err)
+	}
+
+	return id, nil
+}
+
+// Get retrieves a webhook subscription by its ID.
+// If no matching subscription is found, a models.ErrNoRecord error is returned.
+func (m *WebhookModel) Get(ctx context.Context, id int) (WebhookSubscription, error) {
+	query := `
+		SELECT id, created, url, secret, event_types
+		FROM webhook_subscriptions WHERE id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var s WebhookSubscription
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&s.ID, &s.Created, &s.URL, &s.Secret, pq.Array(&s.EventTypes))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return WebhookSubscription{}, ErrNoRecord
+		}
+		return WebhookSubscription{}, contextError(ctx, err)
+	}
+
+	return s, nil
+}
+
+// GetEventPayload retrieves the JSON payload of the outbox event with the
+// given ID. It is used by the events package, since notifications of new
+// events only carry their IDs.
+func (m *WebhookModel) GetEventPayload(ctx context.Context, id int) ([]byte, error) {
+	query := `SELECT payload FROM outbox_events WHERE id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var payload []byte
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&payload)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, contextError(ctx, err)
+	}
+
+	return payload, nil
+}
+
+// GetAll retrieves all webhook subscriptions, oldest first.
+func (m *WebhookModel) GetAll(ctx context.Context) ([]WebhookSubscription, error) {
+	query := `
+		SELECT id, created, url, secret, event_types
+		FROM webhook_subscriptions ORDER BY id`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var subscriptions []WebhookSubscription
+
+	for rows.Next() {
+		var s WebhookSubscription
+		err = rows.Scan(&s.ID, &s.Created, &s.URL, &s.Secret, pq.Array(&s.EventTypes))
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return subscriptions, nil
+}
+
+// Delete removes a webhook subscription, along with its deliveries.
+// If no matching subscription is found, a models.ErrNoRecord error is returned.
+func (m *WebhookModel) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM webhook_subscriptions WHERE id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// GetDeliveries retrieves the most recent deliveries for a subscription,
+// newest first, up to limit.
+func (m *WebhookModel) GetDeliveries(ctx context.Context, subscriptionID int, limit int) ([]WebhookDelivery, error) {
+	query := `
+		SELECT d.id, d.created, d.subscription_id, d.event_id, e.event_type,
+			d.status, d.attempts, d.next_attempt, d.response_code, d.error
+		FROM webhook_deliveries d JOIN outbox_events e ON e.id = d.event_id
+		WHERE d.subscription_id = $1
+		ORDER BY d.id DESC
+		LIMIT $2`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, subscriptionID, limit)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+
+	for rows.Next() {
+		var d WebhookDelivery
+		var responseCode sql.NullInt32
+		err = rows.Scan(&d.ID, &d.Created, &d.SubscriptionID, &d.EventID, &d.EventType,
+			&d.Status, &d.Attempts, &d.NextAttempt, &responseCode, &d.Error)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		d.ResponseCode = int(responseCode.Int32)
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return deliveries, nil
+}
+
+// FanOut processes up to limit unprocessed outbox events, creating a pending
+// delivery for each subscription to the event's type, and marking the events
+// as processed. It returns the number of events processed.
+//
+// Events are locked with FOR UPDATE SKIP LOCKED, so multiple instances of the
+// application can run FanOut concurrently without processing an event twice.
+func (m *WebhookModel) FanOut(ctx context.Context, limit int) (int, error) {
+	query := `
+		WITH events AS (
+			SELECT id, event_type FROM outbox_events
+			WHERE processed IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), deliveries AS (
+			INSERT INTO webhook_deliveries (subscription_id, event_id)
+			SELECT s.id, e.id
+			FROM events e JOIN webhook_subscriptions s ON e.event_type = ANY(s.event_types)
+			ON CONFLICT DO NOTHING
+		)
+		UPDATE outbox_events SET processed = NOW()
+		WHERE id IN (SELECT id FROM events)`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, limit)
+	if err != nil {
+		return 0, contextError(ctx, err)
+	}
+
+	processed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(processed), nil
+}
+
+// ClaimDeliveries retrieves up to limit pending deliveries that are due to be
+// attempted, along with the subscription URL, secret and event payload needed
+// to make each request.
+//
+// The claimed deliveries' next attempt is pushed back by lease, so that they
+// aren't claimed again, by this or another instance, while the requests are in
+// progress. If the instance dies before recording the attempts, they will be
+// retried once the lease expires.
+func (m *WebhookModel) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	query := `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt <= NOW()
+			ORDER BY next_attempt
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt = NOW() + make_interval(secs => $2)
+		FROM due, webhook_subscriptions s, outbox_events e
+		WHERE d.id = due.id AND s.id = d.subscription_id AND e.id = d.event_id
+		RETURNING d.id, d.subscription_id, d.event_id, e.event_type, d.attempts,
+			s.url, s.secret, e.payload`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+
+	for rows.Next() {
+		var d WebhookDelivery
+		err = rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Attempts,
+			&d.URL, &d.Secret, &d.Payload)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	return deliveries, nil
+}
+
+// RecordAttempt records the outcome of a delivery attempt. The Status,
+// Attempts, NextAttempt, ResponseCode and Error fields of d are saved.
+func (m *WebhookModel) RecordAttempt(ctx context.Context, d WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt = $3, response_code = $4, error = $5
+		WHERE id = $6`
+
+	args := []any{
+		d.Status, d.Attempts, d.NextAttempt,
+		sql.NullInt32{Int32: int32(d.ResponseCode), Valid: d.ResponseCode != 0},
+		d.Error, d.ID,
+	}
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	return nil
+}
+
+// Prune deletes the deliveries that succeeded or failed before the given
+// time, and then the events that were processed before it and have no
+// deliveries left. It returns the number of events and deliveries deleted.
+//
+// Pending deliveries, and so their events, are kept however old they are.
+func (m *WebhookModel) Prune(ctx context.Context, before time.Time) (int, int, error) {
+	// A finished delivery's next_attempt is the time of its last attempt.
+	deliveriesQuery := `
+		DELETE FROM webhook_deliveries
+		WHERE status <> 'pending' AND next_attempt < $1`
+
+	eventsQuery := `
+		DELETE FROM outbox_events e
+		WHERE processed < $1
+			AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.event_id = e.id)`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, deliveriesQuery, before)
+	if err != nil {
+		return 0, 0, contextError(ctx, err)
+	}
+
+	deliveries, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	result, err = m.DB.ExecContext(ctx, eventsQuery, before)
+	if err != nil {
+		return 0, 0, contextError(ctx, err)
+	}
+
+	events, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(events), int(deliveries), nil
+}
@@ -0,0 +1,131 @@
+package reminders
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies of a recurrence rule, as in the FREQ part of an RFC 5545
+// RRULE.
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+// Rule is a recurrence rule. It supports the subset of RFC 5545 RRULE values
+// with only FREQ and INTERVAL parts, such as "FREQ=WEEKLY;INTERVAL=2". The
+// zero Rule doesn't recur.
+type Rule struct {
+	Freq     string
+	Interval int
+}
+
+// ParseRule parses a recurrence rule. An empty string is parsed as the zero
+// Rule. INTERVAL defaults to 1.
+func ParseRule(s string) (Rule, error) {
+	if s == "" {
+		return Rule{}, nil
+	}
+
+	r := Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+			switch r.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				return Rule{}, fmt.Errorf("unsupported frequency %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				return Rule{}, fmt.Errorf("invalid interval %q", value)
+			}
+			r.Interval = n
+		default:
+			return Rule{}, fmt.Errorf("unsupported rule part %q", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return Rule{}, errors.New("rule has no frequency")
+	}
+
+	return r, nil
+}
+
+// IsZero reports whether the rule doesn't recur.
+func (r Rule) IsZero() bool {
+	return r.Freq == ""
+}
+
+// String returns the rule in RRULE form, or an empty string for the zero
+// Rule.
+func (r Rule) String() string {
+	switch {
+	case r.IsZero():
+		return ""
+	case r.Interval <= 1:
+		return "FREQ=" + r.Freq
+	default:
+		return fmt.Sprintf("FREQ=%s;INTERVAL=%d", r.Freq, r.Interval)
+	}
+}
+
+// Describe returns a description of the rule, such as "every 2 weeks", or an
+// empty string for the zero Rule.
+func (r Rule) Describe() string {
+	units := map[string]string{Daily: "day", Weekly: "week", Monthly: "month", Yearly: "year"}
+
+	switch {
+	case r.IsZero():
+		return ""
+	case r.Interval <= 1:
+		return "every " + units[r.Freq]
+	default:
+		return fmt.Sprintf("every %d %ss", r.Interval, units[r.Freq])
+	}
+}
+
+// next returns the occurrence after t. Months and years are added with
+// time.AddDate, so an occurrence on a day that doesn't exist in the next
+// month, such as the 31st, is normalized into the month after.
+func (r Rule) next(t time.Time) time.Time {
+	switch r.Freq {
+	case Daily:
+		return t.AddDate(0, 0, r.Interval)
+	case Weekly:
+		return t.AddDate(0, 0, 7*r.Interval)
+	case Monthly:
+		return t.AddDate(0, r.Interval, 0)
+	default:
+		return t.AddDate(r.Interval, 0, 0)
+	}
+}
+
+// NextAfter returns the first occurrence of a series starting at start that
+// is after now, or the zero time if the rule doesn't recur. Occurrences that
+// were missed, for example while the application wasn't running, are
+// skipped.
+func (r Rule) NextAfter(start time.Time, now time.Time) time.Time {
+	if r.IsZero() {
+		return time.Time{}
+	}
+
+	t := r.next(start)
+	for !t.After(now) {
+		t = r.next(t)
+	}
+	return t
+}
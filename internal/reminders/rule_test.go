@@ -0,0 +1,67 @@
+package reminders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestParseRule(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     string
+		expected Rule
+		valid    bool
+	}{
+		{"Empty", "", Rule{}, true},
+		{"Weekly", "FREQ=WEEKLY", Rule{Freq: Weekly, Interval: 1}, true},
+		{"Interval", "FREQ=WEEKLY;INTERVAL=2", Rule{Freq: Weekly, Interval: 2}, true},
+		{"Lowercase", "freq=monthly", Rule{Freq: Monthly, Interval: 1}, true},
+		{"No Frequency", "INTERVAL=2", Rule{}, false},
+		{"Unsupported Frequency", "FREQ=HOURLY", Rule{}, false},
+		{"Zero Interval", "FREQ=DAILY;INTERVAL=0", Rule{}, false},
+		{"Unsupported Part", "FREQ=WEEKLY;BYDAY=TU", Rule{}, false},
+		{"Malformed", "WEEKLY", Rule{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseRule(tc.rule)
+			assert.Equal(t, err == nil, tc.valid)
+			assert.Equal(t, r, tc.expected)
+		})
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	assert.Equal(t, Rule{}.String(), "")
+	assert.Equal(t, Rule{Freq: Yearly, Interval: 1}.String(), "FREQ=YEARLY")
+	assert.Equal(t, Rule{Freq: Weekly, Interval: 2}.String(), "FREQ=WEEKLY;INTERVAL=2")
+	assert.Equal(t, Rule{Freq: Weekly, Interval: 2}.Describe(), "every 2 weeks")
+	assert.Equal(t, Rule{Freq: Daily, Interval: 1}.Describe(), "every day")
+}
+
+func TestNextAfter(t *testing.T) {
+	start := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		rule     Rule
+		now      time.Time
+		expected time.Time
+	}{
+		{"No Recurrence", Rule{}, start, time.Time{}},
+		{"Next Week", Rule{Freq: Weekly, Interval: 1}, start, time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)},
+		{"Skips Missed", Rule{Freq: Daily, Interval: 1}, start.AddDate(0, 0, 3).Add(time.Hour),
+			time.Date(2024, 3, 9, 9, 0, 0, 0, time.UTC)},
+		{"Every 2 Months", Rule{Freq: Monthly, Interval: 2}, start, time.Date(2024, 5, 5, 9, 0, 0, 0, time.UTC)},
+		{"Yearly", Rule{Freq: Yearly, Interval: 1}, start, time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.rule.NextAfter(start, tc.now), tc.expected)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Package reminders fires follow-up reminders for contacts.
+//
+// Reminders are stored in the reminders table (see models.ReminderModel). The
+// Scheduler periodically finds due reminders, adds an in-app notification for
+// each, and emails it to the configured recipient. Recurring reminders are
+// described by a Rule.
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/kvnloughead/contacts-app/internal/mailer"
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+// Scheduler fires due reminders in the background. Multiple instances of the
+// application may run schedulers concurrently, since due reminders are claimed
+// with row-level locks.
+type Scheduler struct {
+	Model  models.ReminderModelInterface
+	Mailer mailer.Mailer
+	Logger *slog.Logger
+
+	// The address reminders are emailed to. If empty, reminders are only
+	// delivered as in-app notifications.
+	Recipient string
+
+	// How often to check for due reminders.
+	PollInterval time.Duration
+
+	// The maximum time to spend emailing a single reminder, including retries.
+	SendTimeout time.Duration
+
+	// The maximum number of reminders to fire per poll.
+	BatchSize int
+}
+
+// Run fires due reminders every PollInterval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.process(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// process fires due reminders and emails them. Errors are logged. A reminder
+// whose email fails isn't retried, since its notification has already been
+// added. If ctx is cancelled, the remaining emails aren't sent, so that
+// shutdown isn't held up by a slow mail server.
+func (s *Scheduler) process(ctx context.Context) {
+	fired, err := s.Model.FireDue(ctx, s.BatchSize)
+	if err != nil {
+		s.Logger.Error("firing reminders failed", "error", err.Error())
+		return
+	}
+
+	for _, r := range fired {
+		s.Logger.Info("reminder fired", "reminder_id", r.ID, "contact_id", r.ContactID)
+
+		if s.Recipient == "" {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			s.Logger.Warn("reminder not emailed (shutting down)", "reminder_id", r.ID)
+			continue
+		}
+
+		err = s.send(ctx, r)
+		if err != nil {
+			s.Logger.Error("emailing reminder failed", "reminder_id", r.ID, "error", err.Error())
+		}
+	}
+}
+
+// send emails the reminder to the recipient, giving up after SendTimeout.
+func (s *Scheduler) send(ctx context.Context, r models.Reminder) error {
+	ctx, cancel := context.WithTimeout(ctx, s.SendTimeout)
+	defer cancel()
+
+	return s.Mailer.Send(ctx, Email(s.Recipient, r))
+}
+
+// Email returns the email message sent to recipient when r fires.
+func Email(recipient string, r models.Reminder) mailer.Message {
+	body := fmt.Sprintf("Reminder to follow up with %s, due %s UTC.\n",
+		r.ContactName, r.Due.UTC().Format("02 Jan 2006 at 15:04"))
+	if r.Note != "" {
+		body += "\n" + r.Note + "\n"
+	}
+
+	return mailer.Message{
+		To:        recipient,
+		Subject:   "Reminder: " + r.ContactName,
+		PlainBody: body,
+	}
+}
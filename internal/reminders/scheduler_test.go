@@ -0,0 +1,87 @@
+package reminders
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/kvnloughead/contacts-app/internal/mailer"
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+func TestEmail(t *testing.T) {
+	r := models.Reminder{
+		ContactName: "Ada Lovelace",
+		Due:         time.Date(2024, 3, 5, 9, 30, 0, 0, time.UTC),
+		Note:        "Ask about the engine",
+	}
+
+	msg := Email("me@example.com", r)
+	assert.Equal(t, msg.To, "me@example.com")
+	assert.Equal(t, msg.Subject, "Reminder: Ada Lovelace")
+	assert.Equal(t, msg.PlainBody, "Reminder to follow up with Ada Lovelace, due 05 Mar 2024 at 09:30 UTC.\n\nAsk about the engine\n")
+}
+
+// fakeModel is a ReminderModelInterface whose FireDue returns the reminders in
+// fired. Its other methods aren't used by the Scheduler.
+type fakeModel struct {
+	models.ReminderModelInterface
+	fired []models.Reminder
+}
+
+func (m *fakeModel) FireDue(ctx context.Context, limit int) ([]models.Reminder, error) {
+	return m.fired, nil
+}
+
+// blockingMailer is a Mailer that blocks until the context is done, like a
+// mail server that never replies, and counts the messages it was given.
+type blockingMailer struct {
+	sent int
+}
+
+func (m *blockingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent++
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// TestSchedulerProcessStops tests that emails time out after SendTimeout, and
+// that no more emails are sent once the scheduler's context is cancelled.
+func TestSchedulerProcessStops(t *testing.T) {
+	model := &fakeModel{fired: []models.Reminder{{ID: 1}, {ID: 2}, {ID: 3}}}
+	m := &blockingMailer{}
+	s := &Scheduler{
+		Model:       model,
+		Mailer:      m,
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Recipient:   "me@example.com",
+		SendTimeout: time.Hour,
+	}
+
+	// The first email is interrupted by the cancellation, and the others
+	// aren't sent.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.process(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected process to return after the context was cancelled")
+	}
+	assert.Equal(t, m.sent, 1)
+
+	// Each email is given up after SendTimeout.
+	m.sent = 0
+	s.SendTimeout = 10 * time.Millisecond
+	s.process(context.Background())
+	assert.Equal(t, m.sent, 3)
+}
@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"net/url"
 	"regexp"
 	"slices"
 	"strings"
@@ -11,6 +12,10 @@ import (
 // https://html.spec.whatwg.org/multipage/input.html#valid-e-mail-address
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// DomainRX matches a domain name with at least two labels, such as
+// "example.com", using the same label rules as EmailRX.
+var DomainRX = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+$`)
+
 // PermissivePhoneNumberRX is a permissive phone number regex for US style
 // phone numbers. For examples of acceptable formats, see validator_test.go.
 var PermissivePhoneNumberRX = regexp.MustCompile(`^\+?(?:\(\d{3}\)|\d{3})[-\s]?\d{3}[-\s]?\d{4}$`)
@@ -88,6 +93,15 @@ func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	return slices.Contains(permittedValues, value)
 }
 
+// Returns true if the string is an absolute http or https URL with a host.
+func HTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 // ValidatePhoneNumberInput checks that the phone number string matches either
 // a permissive US-style phone number regex, or the international E.164 regex.
 func ValidatePhoneNumberInput(phoneNumber string) bool {
@@ -57,3 +57,51 @@ func TestE164PhoneNumber(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPURL(t *testing.T) {
+	testCases := []struct {
+		name  string
+		url   string
+		valid bool
+	}{
+		{"HTTPS", "https://example.com/hooks", true},
+		{"HTTP With Port", "http://localhost:8080/hooks", true},
+		{"Missing Scheme", "example.com/hooks", false},
+		{"Other Scheme", "ftp://example.com", false},
+		{"Missing Host", "https:///hooks", false},
+		{"Empty", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if HTTPURL(tc.url) != tc.valid {
+				t.Errorf("Test %s failed. Expected %t, got %t", tc.name, tc.valid, !tc.valid)
+			}
+		})
+	}
+}
+
+func TestDomainRX(t *testing.T) {
+	testCases := []struct {
+		name   string
+		domain string
+		valid  bool
+	}{
+		{"Valid", "example.com", true},
+		{"Subdomain", "mail.example.co.uk", true},
+		{"Hyphen", "my-company.io", true},
+		{"Single label", "localhost", false},
+		{"Email address", "jane@example.com", false},
+		{"Leading hyphen", "-example.com", false},
+		{"Trailing dot", "example.com.", false},
+		{"Space", "example .com", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if DomainRX.MatchString(tc.domain) != tc.valid {
+				t.Errorf("Test %s failed. Expected %t, got %t", tc.name, tc.valid, !tc.valid)
+			}
+		})
+	}
+}
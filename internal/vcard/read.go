@@ -0,0 +1,282 @@
+package vcard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+// Read parses the vCards in r, which may be in version 3.0 or 4.0, and returns
+// a contact for each of them. Only the properties written by Write are read,
+// except CATEGORIES, and other properties are ignored.
+//
+// Relations are returned by name, in each contact's Related field, since the
+// related contacts may not exist yet. Their Type is read from the
+// X-RELATION parameter written by Write, or otherwise from the TYPE parameter
+// if it has a matching relation type. Relations of any other type are skipped.
+//
+// An error is returned if the data isn't a sequence of vCards.
+func Read(r io.Reader) ([]models.Contact, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var contacts []models.Contact
+	var c *models.Contact
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("vcard: content line %d: %w", i+1, err)
+		}
+
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCARD"):
+			if c != nil {
+				return nil, fmt.Errorf("vcard: content line %d: BEGIN:VCARD inside a vCard", i+1)
+			}
+			c = &models.Contact{}
+		case c == nil:
+			return nil, fmt.Errorf("vcard: content line %d: %s outside a vCard", i+1, p.name)
+		case p.name == "END" && strings.EqualFold(p.value, "VCARD"):
+			contacts = append(contacts, *c)
+			c = nil
+		default:
+			readProperty(c, p)
+		}
+	}
+
+	if c != nil {
+		return nil, fmt.Errorf("vcard: missing END:VCARD")
+	}
+
+	return contacts, nil
+}
+
+// importedRelatedTypes maps values of the TYPE parameter of the RELATED
+// property to relation types, for vCards that weren't written by Write. Values
+// that could mean more than one relation type, such as co-worker, are left out.
+var importedRelatedTypes = map[string]string{
+	"spouse": "spouse",
+	"agent":  "assistant",
+}
+
+// readProperty sets the contact's field for the property, if there is one.
+// Properties that occur more than once, other than RELATED, are only read the
+// first time.
+func readProperty(c *models.Contact, p property) {
+	switch p.name {
+	case "FN":
+		// FN is only used if there's no N property, which is more precise.
+		if c.First == "" && c.Last == "" {
+			name := strings.TrimSpace(unescape(p.value))
+			c.First, c.Last, _ = strings.Cut(name, " ")
+		}
+	case "N":
+		// N is the family name, followed by the given name and other components.
+		components := splitValue(p.value, ';')
+		last, first := unescape(components[0]), ""
+		if len(components) > 1 {
+			first = unescape(components[1])
+		}
+		if first != "" || last != "" {
+			c.First, c.Last = first, last
+		}
+	case "TEL":
+		if c.Phone == "" {
+			// In vCard 4.0 the number may be a tel: URI.
+			c.Phone = strings.TrimPrefix(unescape(p.value), "tel:")
+		}
+	case "EMAIL":
+		if c.Email == "" {
+			c.Email = unescape(p.value)
+		}
+	case "ORG":
+		if c.Company == "" {
+			// ORG is the organization name followed by its units.
+			components := splitValue(p.value, ';')
+			c.Company = unescape(components[0])
+			if len(components) > 1 {
+				c.Department = unescape(components[1])
+			}
+		}
+	case "TITLE":
+		if c.Title == "" {
+			c.Title = unescape(p.value)
+		}
+	case "BDAY":
+		// Birthdays without a year, or that aren't dates, are skipped.
+		for _, layout := range []string{"20060102", "2006-01-02"} {
+			if t, err := time.Parse(layout, p.value); err == nil {
+				c.Birthday = t
+				break
+			}
+		}
+	case "RELATED":
+		// Related contacts given by URI, such as a UID, can't be resolved.
+		if !strings.EqualFold(p.param("VALUE"), "text") {
+			return
+		}
+		relType := strings.ToLower(p.param("X-RELATION"))
+		if !slices.ContainsFunc(models.RelationTypes, func(t models.RelationType) bool { return t.Name == relType }) {
+			relType = ""
+			for _, t := range strings.Split(strings.ToLower(p.param("TYPE")), ",") {
+				if name, ok := importedRelatedTypes[t]; ok {
+					relType = name
+					break
+				}
+			}
+		}
+		name := strings.TrimSpace(unescape(p.value))
+		if relType != "" && name != "" {
+			c.Related = append(c.Related, models.Relation{Type: relType, RelatedName: name})
+		}
+	}
+}
+
+// property is a parsed content line. The name and the parameter names are
+// upper case, and the value is still escaped.
+type property struct {
+	name   string
+	params map[string][]string
+	value  string
+}
+
+// param returns the values of the named parameter, joined by commas.
+func (p property) param(name string) string {
+	return strings.Join(p.params[name], ",")
+}
+
+// parseLine parses an unfolded content line, which has the form
+// "[group.]name *(;param=value) : value".
+func parseLine(line string) (property, error) {
+	p := property{params: map[string][]string{}}
+
+	// The name and parameters end at the first colon that isn't in a quoted
+	// parameter value.
+	end, quoted := -1, false
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return property{}, fmt.Errorf("missing colon")
+	}
+	p.value = line[end+1:]
+
+	parts := splitParams(line[:end])
+	p.name = strings.ToUpper(parts[0])
+	if _, name, ok := strings.Cut(p.name, "."); ok {
+		p.name = name
+	}
+	if p.name == "" {
+		return property{}, fmt.Errorf("missing property name")
+	}
+
+	for _, part := range parts[1:] {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			// vCard 3.0 allows a type without the "TYPE=", as in "TEL;CELL".
+			name, value = "TYPE", part
+		}
+		name = strings.ToUpper(name)
+		for _, v := range splitParams(value) {
+			p.params[name] = append(p.params[name], strings.Trim(v, `"`))
+		}
+	}
+
+	return p, nil
+}
+
+// splitParams splits the parameters of a content line at semicolons, or the
+// values of a parameter at commas, ignoring those in quoted values.
+func splitParams(s string) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case (r == ';' || r == ',') && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitValue splits a structured value, such as N or ORG, at the separator
+// when it isn't escaped. The components are still escaped.
+func splitValue(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescape reverses escape. Unknown escapes are left as they are.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		case '\\', ',', ';':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// unfold reads the content lines in r, joining folded lines. A line that
+// begins with a space or a tab continues the previous line. Lines may end
+// with CRLF or LF.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
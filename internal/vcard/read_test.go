@@ -0,0 +1,160 @@
+package vcard
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+func TestRead(t *testing.T) {
+	input := "BEGIN:VCARD\r\n" +
+		"VERSION:4.0\r\n" +
+		"FN:Jane Doe\\, Jr.\r\n" +
+		"N:Doe\\, Jr.;Jane;;;\r\n" +
+		"item1.TEL;TYPE=\"cell,voice\":tel:+15551234567\r\n" +
+		"EMAIL;PREF=1:jane@example.com\r\n" +
+		"EMAIL:other@example.com\r\n" +
+		"ORG:Acme\\; Co.;Sales\r\n" +
+		"TITLE:Account \r\n" +
+		" Manager\r\n" +
+		"BDAY:1990-03-05\r\n" +
+		"NOTE:Ignored\r\n" +
+		"END:VCARD\r\n" +
+		"\r\n" +
+		"begin:vcard\n" +
+		"version:3.0\n" +
+		"fn:John Smith\n" +
+		"tel;cell:555-0100\n" +
+		"end:vcard\n"
+
+	contacts, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []models.Contact{
+		{
+			First:      "Jane",
+			Last:       "Doe, Jr.",
+			Phone:      "+15551234567",
+			Email:      "jane@example.com",
+			Company:    "Acme; Co.",
+			Department: "Sales",
+			Title:      "Account Manager",
+			Birthday:   time.Date(1990, 3, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{First: "John", Last: "Smith", Phone: "555-0100"},
+	}
+
+	if !reflect.DeepEqual(contacts, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, contacts)
+	}
+}
+
+func TestReadRelated(t *testing.T) {
+	input := "BEGIN:VCARD\r\n" +
+		"N:Doe;Jane;;;\r\n" +
+		"RELATED;TYPE=co-worker;X-RELATION=manager;VALUE=text:Smith\\, Ann\r\n" +
+		"RELATED;TYPE=spouse;VALUE=text:John Doe\r\n" +
+		"RELATED;TYPE=agent;VALUE=text:Bob\r\n" +
+		"RELATED;TYPE=co-worker;VALUE=text:Skipped\r\n" +
+		"RELATED;TYPE=spouse:urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6\r\n" +
+		"END:VCARD\r\n"
+
+	contacts, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []models.Relation{
+		{Type: "manager", RelatedName: "Smith, Ann"},
+		{Type: "spouse", RelatedName: "John Doe"},
+		{Type: "assistant", RelatedName: "Bob"},
+	}
+
+	if len(contacts) != 1 || !reflect.DeepEqual(contacts[0].Related, expected) {
+		t.Errorf("Expected relations %+v, got %+v", expected, contacts)
+	}
+}
+
+// TestReadWrite tests that contacts written by Write are read back unchanged.
+func TestReadWrite(t *testing.T) {
+	contact := models.Contact{
+		First:      "Jane",
+		Last:       "Doe; Jr.",
+		Phone:      "+15551234567",
+		Email:      "jane@example.com",
+		Company:    "Acme, Inc.",
+		Department: "Sales",
+		Title:      strings.Repeat("Very long title ", 10),
+		Birthday:   time.Date(1990, 3, 5, 0, 0, 0, 0, time.UTC),
+		Related: []models.Relation{
+			{Type: "report", RelatedName: "Ann Smith"},
+			{Type: "referrer", RelatedName: "Bob"},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := Write(&buf, contact)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contacts, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(contacts) != 1 || !reflect.DeepEqual(contacts[0], contact) {
+		t.Errorf("Expected %+v, got %+v", contact, contacts)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"Not A vCard", "Hello, world!\r\n"},
+		{"Missing End", "BEGIN:VCARD\r\nFN:Jane\r\n"},
+		{"Nested", "BEGIN:VCARD\r\nBEGIN:VCARD\r\nEND:VCARD\r\nEND:VCARD\r\n"},
+		{"Missing Colon", "BEGIN:VCARD\r\nFN\r\nEND:VCARD\r\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tc.input))
+			if err == nil {
+				t.Errorf("Test %s failed. Expected an error, got nil", tc.name)
+			}
+		})
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"No Special Characters", "Jane", "Jane"},
+		{"Comma", `Doe\, Jr.`, "Doe, Jr."},
+		{"Semicolon", `a\;b`, "a;b"},
+		{"Backslash", `a\\b`, `a\b`},
+		{"Newline", `a\nb`, "a\nb"},
+		{"Unknown Escape", `a\xb`, `a\xb`},
+		{"Trailing Backslash", `a\`, `a\`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := unescape(tc.value); got != tc.expected {
+				t.Errorf("Test %s failed. Expected %q, got %q", tc.name, tc.expected, got)
+			}
+		})
+	}
+}
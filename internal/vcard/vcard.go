@@ -0,0 +1,135 @@
+// Package vcard encodes contacts in the vCard 4.0 format, as specified by RFC
+// 6350, and decodes them from vCard 3.0 or 4.0 files.
+// https://datatracker.ietf.org/doc/html/rfc6350
+package vcard
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+// ContentType is the media type of vCard data.
+const ContentType = "text/vcard; charset=utf-8"
+
+// maxLineLength is the maximum length of a content line in octets, excluding
+// the line break. Longer lines must be folded.
+const maxLineLength = 75
+
+// relatedTypes maps relation types to the closest value of the TYPE
+// parameter of the RELATED property.
+var relatedTypes = map[string]string{
+	"spouse":    "spouse",
+	"manager":   "co-worker",
+	"report":    "co-worker",
+	"assistant": "agent",
+	"executive": "co-worker",
+	"referrer":  "contact",
+	"referral":  "contact",
+}
+
+// Write writes each contact to w as a vCard. Multiple vCards may be stored in
+// a single .vcf file.
+func Write(w io.Writer, contacts ...models.Contact) error {
+	bw := bufio.NewWriter(w)
+
+	for _, c := range contacts {
+		writeLine(bw, "BEGIN:VCARD")
+		writeLine(bw, "VERSION:4.0")
+		writeLine(bw, "FN:"+escape(strings.TrimSpace(c.First+" "+c.Last)))
+		writeLine(bw, "N:"+escape(c.Last)+";"+escape(c.First)+";;;")
+		if c.Phone != "" {
+			writeLine(bw, "TEL:"+escape(c.Phone))
+		}
+		if c.Email != "" {
+			writeLine(bw, "EMAIL:"+escape(c.Email))
+		}
+		if c.Company != "" || c.Department != "" {
+			// ORG is the organization name followed by its units.
+			org := escape(c.Company)
+			if c.Department != "" {
+				org += ";" + escape(c.Department)
+			}
+			writeLine(bw, "ORG:"+org)
+		}
+		if c.Title != "" {
+			writeLine(bw, "TITLE:"+escape(c.Title))
+		}
+		if !c.Birthday.IsZero() {
+			writeLine(bw, "BDAY:"+c.Birthday.Format("20060102"))
+		}
+		for _, rel := range c.Related {
+			// Related contacts are exported by name, since they may not be part of
+			// the same export. TYPE can't express every relation type, so the
+			// exact type is kept in X-RELATION for Read.
+			params := ";VALUE=text"
+			if t, ok := relatedTypes[rel.Type]; ok {
+				params = ";TYPE=" + t + ";X-RELATION=" + rel.Type + params
+			}
+			writeLine(bw, "RELATED"+params+":"+escape(rel.RelatedName))
+		}
+		if len(c.Tags) > 0 {
+			// Tags are exported as categories, which are separated by commas.
+			categories := make([]string, len(c.Tags))
+			for i, tag := range c.Tags {
+				categories[i] = escape(tag)
+			}
+			writeLine(bw, "CATEGORIES:"+strings.Join(categories, ","))
+		}
+		writeLine(bw, "END:VCARD")
+	}
+
+	return bw.Flush()
+}
+
+// escape escapes a text value. Backslashes, commas and semicolons are escaped
+// with a backslash, and newlines are replaced by \n.
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		",", `\,`,
+		";", `\;`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// writeLine writes a content line, terminated by CRLF. Lines longer than
+// maxLineLength octets are folded by inserting CRLF followed by a space,
+// taking care not to split multi-octet UTF-8 characters.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(line[i]) {
+			i--
+		}
+		w.WriteString(line[:i])
+		w.WriteString("\r\n ")
+		line = line[i:]
+
+		// Continuation lines begin with a space, which counts towards the limit.
+		limit = maxLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// Filename returns a filename for a .vcf file containing the contact, such as
+// "Jane Doe.vcf". Characters that are unsafe in filenames are removed.
+func Filename(c models.Contact) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(c.First+" "+c.Last))
+
+	if name == "" {
+		name = "contact"
+	}
+	return name + ".vcf"
+}
@@ -0,0 +1,135 @@
+package vcard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, models.Contact{First: "Jane", Last: "Doe, Jr.", Phone: "+15551234567", Email: "jane@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "BEGIN:VCARD\r\n" +
+		"VERSION:4.0\r\n" +
+		"FN:Jane Doe\\, Jr.\r\n" +
+		"N:Doe\\, Jr.;Jane;;;\r\n" +
+		"TEL:+15551234567\r\n" +
+		"EMAIL:jane@example.com\r\n" +
+		"END:VCARD\r\n"
+
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, buf.String())
+	}
+}
+
+func TestWriteCategories(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, models.Contact{First: "Jane", Last: "Doe", Tags: []string{"customer", "vip, gold"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "CATEGORIES:customer,vip\\, gold\r\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected output to contain %q, got:\n%q", expected, buf.String())
+	}
+}
+
+func TestWriteOrganization(t *testing.T) {
+	testCases := []struct {
+		name     string
+		contact  models.Contact
+		expected []string
+	}{
+		{"Company", models.Contact{Company: "Acme, Inc."}, []string{"ORG:Acme\\, Inc.\r\n"}},
+		{"Company and Department", models.Contact{Company: "Acme", Department: "Sales"}, []string{"ORG:Acme;Sales\r\n"}},
+		{"Title", models.Contact{Title: "Account Manager"}, []string{"TITLE:Account Manager\r\n"}},
+		{"Birthday", models.Contact{Birthday: time.Date(1990, 3, 5, 0, 0, 0, 0, time.UTC)}, []string{"BDAY:19900305\r\n"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Write(&buf, tc.contact)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, expected := range tc.expected {
+				if !strings.Contains(buf.String(), expected) {
+					t.Errorf("Expected output to contain %q, got:\n%q", expected, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWriteRelated(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, models.Contact{First: "Jane", Last: "Doe", Related: []models.Relation{
+		{Type: "spouse", RelatedName: "John Doe"},
+		{Type: "manager", RelatedName: "Smith, Ann"},
+		{Type: "unknown", RelatedName: "Bob"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"RELATED;TYPE=spouse;X-RELATION=spouse;VALUE=text:John Doe\r\n",
+		"RELATED;TYPE=co-worker;X-RELATION=manager;VALUE=text:Smith\\, Ann\r\n",
+		"RELATED;VALUE=text:Bob\r\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%q", expected, buf.String())
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"No Special Characters", "Jane", "Jane"},
+		{"Comma", "Doe, Jr.", `Doe\, Jr.`},
+		{"Semicolon", "a;b", `a\;b`},
+		{"Backslash", `a\b`, `a\\b`},
+		{"Newline", "a\nb", `a\nb`},
+		{"CRLF", "a\r\nb", `a\nb`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := escape(tc.value); got != tc.expected {
+				t.Errorf("Test %s failed. Expected %q, got %q", tc.name, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestLineFolding(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, models.Contact{First: strings.Repeat("é", 60), Last: "Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("Line exceeds %d octets: %q", maxLineLength, line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("Line splits a multi-octet character: %q", line)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a webhook URL resolves to an address
+// that deliveries may not be sent to (see AllowedAddr).
+var ErrForbiddenAddress = errors.New("webhooks: forbidden destination address")
+
+// NewClient returns an HTTP client for delivering webhooks, with the given
+// timeout per request.
+//
+// Anyone can create a webhook, so the client must not be usable to reach
+// services on the server's own network. The address is checked as each
+// connection is dialed, after DNS resolution, so a hostname can't pass
+// validation and then rebind to a private address. Redirects aren't
+// followed, and proxies from the environment aren't used, since either would
+// send the request to an address that hasn't been checked.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: checkDialAddress,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkDialAddress is a net.Dialer Control function that refuses to connect
+// to addresses that aren't allowed by AllowedAddr.
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	if !AllowedAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// AllowedAddr returns true if webhooks may be delivered to addr. Loopback,
+// private (RFC 1918 and RFC 4193), link-local, including the cloud metadata
+// address 169.254.169.254, unspecified and multicast addresses aren't allowed.
+func AllowedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
+
+// AllowedHost returns false if host is "localhost" or an IP address that
+// isn't allowed by AllowedAddr. Other hostnames are allowed, since they are
+// only checked when they are resolved at delivery time. It is used to reject
+// obviously forbidden URLs when a webhook is created.
+func AllowedHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return true
+	}
+	return AllowedAddr(addr)
+}
@@ -0,0 +1,92 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestClientRejectsForbiddenAddresses(t *testing.T) {
+	// A server on the loopback interface, which must not be reached.
+	reached := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer ts.Close()
+
+	client := NewClient(time.Second)
+
+	testCases := []struct {
+		name string
+		url  string
+	}{
+		{"Loopback", "http://127.0.0.1/"},
+		{"Loopback Test Server", ts.URL},
+		{"Metadata Service", "http://169.254.169.254/"},
+		{"Private", "http://10.0.0.1/"},
+		{"IPv6 Loopback", "http://[::1]/"},
+		{"IPv4-Mapped Loopback", "http://[::ffff:127.0.0.1]/"},
+		{"Unspecified", "http://0.0.0.0/"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := client.Post(tc.url, "application/json", nil)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if !errors.Is(err, ErrForbiddenAddress) {
+				t.Errorf("Test %s failed. Expected ErrForbiddenAddress, got %v", tc.name, err)
+			}
+		})
+	}
+
+	if reached {
+		t.Error("Expected the loopback server not to be reached")
+	}
+}
+
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	client := NewClient(time.Second)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/hook", nil)
+	err := client.CheckRedirect(req, []*http.Request{req})
+	if !errors.Is(err, http.ErrUseLastResponse) {
+		t.Errorf("Expected http.ErrUseLastResponse, got %v", err)
+	}
+}
+
+func TestAllowedHost(t *testing.T) {
+	testCases := []struct {
+		host    string
+		allowed bool
+	}{
+		{"example.com", true},
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::", true},
+		{"localhost", false},
+		{"api.localhost", false},
+		{"127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"192.168.1.10", false},
+		{"172.16.0.1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			if got := AllowedHost(tc.host); got != tc.allowed {
+				t.Errorf("Test %s failed. Expected %t, got %t", tc.host, tc.allowed, got)
+			}
+		})
+	}
+
+	if AllowedAddr(netip.Addr{}) {
+		t.Error("Expected the zero Addr not to be allowed")
+	}
+}
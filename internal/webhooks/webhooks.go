@@ -0,0 +1,236 @@
+// Package webhooks delivers contact change events to webhook subscribers.
+//
+// Events are written to the outbox_events table in the same transaction as
+// the change they describe (see models.ContactModel). The Worker periodically
+// fans new events out into a pending delivery per subscription, and then
+// POSTs each due delivery's payload to the subscription's URL, retrying
+// failures with exponential backoff. Processed events and finished deliveries
+// are deleted once they are older than the Worker's Retention.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+// MaxAttempts is the number of times a delivery is attempted before it is
+// marked as failed.
+const MaxAttempts = 8
+
+// pruneInterval is how often old events and deliveries are deleted.
+const pruneInterval = time.Hour
+
+// Worker delivers webhooks in the background. Multiple instances of the
+// application may run workers concurrently, since deliveries are claimed with
+// row-level locks.
+type Worker struct {
+	Model  models.WebhookModelInterface
+	Logger *slog.Logger
+
+	// The HTTP client used to make requests. Its Timeout should be set. Use
+	// NewClient, so that requests can't be made to the server's own network.
+	Client *http.Client
+
+	// How often to check for new events and due deliveries.
+	PollInterval time.Duration
+
+	// The maximum number of events and deliveries to process per poll.
+	BatchSize int
+
+	// How long to keep processed events and finished deliveries, which are
+	// shown on the webhook's page. If zero, they are kept forever.
+	Retention time.Duration
+
+	// When old events and deliveries were last deleted.
+	lastPrune time.Time
+}
+
+// Run processes events and deliveries every PollInterval until ctx is
+// cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.process(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// process fans out new outbox events and then attempts all due deliveries
+// concurrently. At most once every pruneInterval, it first deletes events and
+// deliveries older than Retention. Errors are logged, and the work is retried
+// on the next poll.
+func (w *Worker) process(ctx context.Context) {
+	if w.Retention > 0 && time.Since(w.lastPrune) >= pruneInterval {
+		w.lastPrune = time.Now()
+		w.prune(ctx)
+	}
+
+	_, err := w.Model.FanOut(ctx, w.BatchSize)
+	if err != nil {
+		w.Logger.Error("webhook fan out failed", "error", err.Error())
+	}
+
+	// Claimed deliveries aren't claimed again until the lease expires, which
+	// must be longer than a request can take.
+	lease := 2 * w.Client.Timeout
+	deliveries, err := w.Model.ClaimDeliveries(ctx, w.BatchSize, lease)
+	if err != nil {
+		w.Logger.Error("claiming webhook deliveries failed", "error", err.Error())
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		go func(d models.WebhookDelivery) {
+			defer wg.Done()
+			defer func() {
+				if err := recover(); err != nil {
+					w.Logger.Error(fmt.Sprint(err), "delivery_id", d.ID)
+				}
+			}()
+
+			d = w.attempt(ctx, d)
+
+			// Record the result even if ctx was cancelled during the request, so
+			// that the attempt isn't lost on shutdown.
+			err := w.Model.RecordAttempt(context.WithoutCancel(ctx), d)
+			if err != nil {
+				w.Logger.Error("recording webhook delivery failed", "delivery_id", d.ID, "error", err.Error())
+			}
+		}(d)
+	}
+	wg.Wait()
+}
+
+// prune deletes the processed events and finished deliveries that are older
+// than Retention.
+func (w *Worker) prune(ctx context.Context) {
+	events, deliveries, err := w.Model.Prune(ctx, time.Now().Add(-w.Retention))
+	if err != nil {
+		w.Logger.Error("pruning webhook events failed", "error", err.Error())
+		return
+	}
+
+	if events > 0 || deliveries > 0 {
+		w.Logger.Info("webhook events pruned", "events", events, "deliveries", deliveries)
+	}
+}
+
+// attempt makes a single delivery attempt, and returns the delivery with its
+// status, attempt count, next attempt time, response code and error updated.
+func (w *Worker) attempt(ctx context.Context, d models.WebhookDelivery) models.WebhookDelivery {
+	d.Attempts++
+	d.NextAttempt = time.Now()
+
+	code, err := w.post(ctx, d)
+	d.ResponseCode = code
+
+	switch {
+	case err == nil:
+		d.Status = models.DeliverySucceeded
+	case d.Attempts >= MaxAttempts:
+		d.Status = models.DeliveryFailed
+		d.Error = err.Error()
+	default:
+		d.Status = models.DeliveryPending
+		d.Error = err.Error()
+		d.NextAttempt = time.Now().Add(Backoff(d.Attempts))
+	}
+
+	w.Logger.Info("webhook delivery attempted",
+		"delivery_id", d.ID,
+		"event", d.EventType,
+		"attempt", d.Attempts,
+		"status", d.Status,
+		"response_code", d.ResponseCode,
+	)
+
+	return d
+}
+
+// post sends the delivery's payload to its URL, signed with the subscription
+// secret. It returns the response status code, if there was a response, and
+// an error unless the status code was 2xx.
+func (w *Worker) post(ctx context.Context, d models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Contact.app-Webhooks/1.0")
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(d.ID))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(d.Secret, timestamp, d.Payload))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Read (a limited amount of) the body, so that the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the value of the X-Webhook-Signature header for a payload sent
+// at the given Unix timestamp. It has the form "sha256=<hex digest>", where
+// the digest is the HMAC-SHA256 of "<timestamp>.<payload>" keyed with the
+// subscription's secret.
+//
+// Receivers should compute the same value from the X-Webhook-Timestamp header
+// and the request body, compare the two in constant time, and reject requests
+// with old timestamps to prevent replays.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long to wait before retrying after the given number of
+// failed attempts. It starts at 30 seconds and doubles after each attempt, up
+// to a maximum of 6 hours.
+func Backoff(attempts int) time.Duration {
+	const (
+		base    = 30 * time.Second
+		maximum = 6 * time.Hour
+	)
+
+	switch {
+	case attempts < 1:
+		return base
+	case attempts > 20: // avoid overflowing the shift below
+		return maximum
+	}
+
+	return min(base<<(attempts-1), maximum)
+}
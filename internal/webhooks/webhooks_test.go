@@ -0,0 +1,121 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+
+	"github.com/kvnloughead/contacts-app/internal/models"
+)
+
+func TestSign(t *testing.T) {
+	testCases := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		payload   string
+		expected  string
+	}{
+		{
+			name:      "Empty Payload",
+			secret:    "secret",
+			timestamp: 1700000000,
+			payload:   "",
+			expected:  "sha256=4bc5f74d868b97888288889c5d9d65df02526f94c1592a79fdf4fe8b26e311e5",
+		},
+		{
+			name:      "JSON Payload",
+			secret:    "secret",
+			timestamp: 1700000000,
+			payload:   `{"event":"contact.created"}`,
+			expected:  "sha256=d7841eea2c3d83f1945cbb56ab613453854396d62a02613e6ebb886b0e7f8023",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, Sign(tc.secret, tc.timestamp, []byte(tc.payload)), tc.expected)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		attempts int
+		expected time.Duration
+	}{
+		{"First Retry", 1, 30 * time.Second},
+		{"Second Retry", 2, time.Minute},
+		{"Fifth Retry", 5, 8 * time.Minute},
+		{"Capped", 11, 6 * time.Hour},
+		{"Overflow", 100, 6 * time.Hour},
+		{"Zero Attempts", 0, 30 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, Backoff(tc.attempts), tc.expected)
+		})
+	}
+}
+
+// fakeModel is a WebhookModelInterface with no events or deliveries, which
+// records the times passed to Prune. Its other methods aren't implemented.
+type fakeModel struct {
+	models.WebhookModelInterface
+	pruned []time.Time
+}
+
+func (m *fakeModel) FanOut(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *fakeModel) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *fakeModel) Prune(ctx context.Context, before time.Time) (int, int, error) {
+	m.pruned = append(m.pruned, before)
+	return 0, 0, nil
+}
+
+// TestWorkerPrune tests that old events are pruned at most once every
+// pruneInterval, and not at all if Retention is zero.
+func TestWorkerPrune(t *testing.T) {
+	testCases := []struct {
+		name      string
+		retention time.Duration
+		pruned    int
+	}{
+		{"Retention", 24 * time.Hour, 1},
+		{"No Retention", 0, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			model := &fakeModel{}
+			w := &Worker{
+				Model:     model,
+				Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+				Client:    &http.Client{Timeout: time.Second},
+				BatchSize: 10,
+				Retention: tc.retention,
+			}
+
+			w.process(context.Background())
+			w.process(context.Background())
+
+			assert.Equal(t, len(model.pruned), tc.pruned)
+			if tc.pruned > 0 {
+				age := time.Since(model.pruned[0])
+				assert.Equal(t, age >= tc.retention && age < tc.retention+time.Minute, true)
+			}
+		})
+	}
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS contact_shares;
\ No newline at end of file
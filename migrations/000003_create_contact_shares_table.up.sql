@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS contact_shares (
+    id bigserial PRIMARY KEY,
+    contact_id bigint NOT NULL REFERENCES contacts ON DELETE CASCADE,
+    token_hash bytea NOT NULL UNIQUE,
+    created timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expires timestamp(0) with time zone NOT NULL,
+    revoked boolean NOT NULL DEFAULT false,
+    access_count integer NOT NULL DEFAULT 0,
+    last_accessed timestamp(0) with time zone
+);
+
+CREATE INDEX contact_shares_contact_id_idx ON contact_shares (contact_id);
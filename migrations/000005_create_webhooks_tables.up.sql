@@ -0,0 +1,36 @@
+-- Events are written to the outbox in the same transaction as the change to
+-- the contact, and later fanned out to webhook_deliveries by the webhook
+-- worker. This ensures that no events are lost if the process crashes after
+-- the change is committed.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id bigserial PRIMARY KEY,
+    created timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    processed timestamp(0) with time zone
+);
+
+CREATE INDEX outbox_events_unprocessed_idx ON outbox_events (id) WHERE processed IS NULL;
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id bigserial PRIMARY KEY,
+    created timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    url text NOT NULL,
+    secret text NOT NULL,
+    event_types text[] NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id bigserial PRIMARY KEY,
+    created timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    subscription_id bigint NOT NULL REFERENCES webhook_subscriptions ON DELETE CASCADE,
+    event_id bigint NOT NULL REFERENCES outbox_events ON DELETE CASCADE,
+    status text NOT NULL DEFAULT 'pending',
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    response_code integer,
+    error text NOT NULL DEFAULT '',
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt) WHERE status = 'pending';
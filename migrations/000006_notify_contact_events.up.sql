@@ -0,0 +1,18 @@
+-- Notify listeners of each contact change event written to the outbox. The
+-- notification is sent when the transaction commits, so that every instance of
+-- the application can stream changes to its clients (see the events package).
+--
+-- Only the event's ID and type are sent, rather than its payload. NOTIFY
+-- payloads are limited to 8000 bytes, and the trigger runs in the transaction
+-- that changes the contact, so a large contact would make the change fail.
+-- Listeners load the payload from outbox_events instead.
+CREATE OR REPLACE FUNCTION notify_contact_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('contact_events', json_build_object('id', NEW.id, 'event', NEW.event_type)::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER outbox_events_notify
+AFTER INSERT ON outbox_events
+FOR EACH ROW EXECUTE FUNCTION notify_contact_event();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS contacts_tags_idx;
+
+ALTER TABLE contacts DROP COLUMN IF EXISTS tags;
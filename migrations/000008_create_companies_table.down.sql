@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS contacts_company_id_idx;
+
+ALTER TABLE contacts DROP COLUMN IF EXISTS department;
+ALTER TABLE contacts DROP COLUMN IF EXISTS title;
+ALTER TABLE contacts DROP COLUMN IF EXISTS company_id;
+
+DROP TABLE IF EXISTS companies;
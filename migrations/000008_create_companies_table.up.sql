@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS companies (
+    id bigserial PRIMARY KEY,
+    created timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    domain text NOT NULL DEFAULT '',
+    notes text NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS companies_name_idx ON companies (lower(name));
+CREATE UNIQUE INDEX IF NOT EXISTS companies_domain_idx ON companies (lower(domain)) WHERE domain <> '';
+
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS company_id bigint REFERENCES companies ON DELETE SET NULL;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS title text NOT NULL DEFAULT '';
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS department text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS contacts_company_id_idx ON contacts (company_id);
@@ -0,0 +1,15 @@
+-- A relation records that related_id is contact_id's <type>, for example that
+-- related_id is contact_id's manager. Bidirectional relations are also shown
+-- on the related contact, with the inverse type (see models.RelationTypes).
+CREATE TABLE IF NOT EXISTS contact_relations (
+    id bigserial PRIMARY KEY,
+    created timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    contact_id bigint NOT NULL REFERENCES contacts ON DELETE CASCADE,
+    related_id bigint NOT NULL REFERENCES contacts ON DELETE CASCADE,
+    type text NOT NULL,
+    bidirectional boolean NOT NULL DEFAULT true,
+    CHECK (contact_id <> related_id),
+    UNIQUE (contact_id, related_id, type)
+);
+
+CREATE INDEX IF NOT EXISTS contact_relations_related_id_idx ON contact_relations (related_id);
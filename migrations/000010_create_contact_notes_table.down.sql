@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS contacts_last_contacted_idx;
+
+ALTER TABLE contacts DROP COLUMN IF EXISTS last_contacted;
+
+DROP TABLE IF EXISTS contact_notes;
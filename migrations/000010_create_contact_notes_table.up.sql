@@ -0,0 +1,20 @@
+-- Notes and logged interactions (calls, meetings and emails) with a contact.
+-- The body is Markdown. occurred is when the interaction took place, which
+-- may be earlier than when it was logged.
+CREATE TABLE IF NOT EXISTS contact_notes (
+    id bigserial PRIMARY KEY,
+    created timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    occurred timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    contact_id bigint NOT NULL REFERENCES contacts ON DELETE CASCADE,
+    kind text NOT NULL,
+    author text NOT NULL DEFAULT '',
+    body text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS contact_notes_contact_id_occurred_idx ON contact_notes (contact_id, occurred DESC);
+
+-- The time of the most recent interaction, kept up to date by
+-- models.NoteModel so that the contacts list can be sorted by it.
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS last_contacted timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS contacts_last_contacted_idx ON contacts (last_contacted);
@@ -0,0 +1,31 @@
+-- Follow-up reminders for a contact. recurrence is an RRULE such as
+-- "FREQ=WEEKLY;INTERVAL=2", or empty for a reminder that doesn't repeat.
+-- fired is set when the notification for the current due time has been sent,
+-- so that it is only sent once. Completing a recurring reminder advances due
+-- to its next occurrence and clears fired, and completing any other reminder
+-- sets done.
+CREATE TABLE IF NOT EXISTS reminders (
+    id bigserial PRIMARY KEY,
+    created timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    contact_id bigint NOT NULL REFERENCES contacts ON DELETE CASCADE,
+    due timestamp(0) with time zone NOT NULL,
+    note text NOT NULL DEFAULT '',
+    recurrence text NOT NULL DEFAULT '',
+    fired timestamp(0) with time zone,
+    done boolean NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS reminders_contact_id_idx ON reminders (contact_id);
+CREATE INDEX IF NOT EXISTS reminders_due_idx ON reminders (due) WHERE NOT done;
+
+-- In-app notifications, written by the reminder scheduler.
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    created timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    contact_id bigint NOT NULL REFERENCES contacts ON DELETE CASCADE,
+    reminder_id bigint REFERENCES reminders ON DELETE SET NULL,
+    message text NOT NULL,
+    read timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS notifications_created_idx ON notifications (created DESC);
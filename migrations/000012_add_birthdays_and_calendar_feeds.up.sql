@@ -0,0 +1,10 @@
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS birthday date;
+
+-- The secret token of the iCalendar feed of birthdays and reminders. Only a
+-- hash of the token is stored, as for contact_shares. Rotating the token
+-- replaces the row, so that the old feed URL stops working.
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    id bigserial PRIMARY KEY,
+    created timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    token_hash bytea NOT NULL UNIQUE
+);
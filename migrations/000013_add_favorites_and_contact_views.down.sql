@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS contact_views;
+
+DROP INDEX IF EXISTS contacts_favorite_idx;
+
+ALTER TABLE contacts DROP COLUMN IF EXISTS favorite;
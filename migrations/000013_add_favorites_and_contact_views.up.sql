@@ -0,0 +1,13 @@
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS favorite boolean NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS contacts_favorite_idx ON contacts (id) WHERE favorite;
+
+-- How often and how recently each contact's page has been viewed, for the
+-- recently viewed list on the home page.
+CREATE TABLE IF NOT EXISTS contact_views (
+    contact_id bigint PRIMARY KEY REFERENCES contacts ON DELETE CASCADE,
+    view_count integer NOT NULL DEFAULT 1,
+    last_viewed timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS contact_views_last_viewed_idx ON contact_views (last_viewed DESC);
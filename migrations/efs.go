@@ -0,0 +1,12 @@
+// Package migrations embeds the SQL migration files in our Go binary, so that
+// the application can compare the migrations it was built with against the
+// version recorded in the database.
+//
+// The migrations themselves are applied with the migrate CLI (see the
+// db/migrations targets in the Makefile).
+package migrations
+
+import "embed"
+
+//go:embed "*.sql"
+var Files embed.FS
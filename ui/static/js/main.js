@@ -5,4 +5,151 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// HTMX responds to form errors with 422 Unprocessable Entity, and to edit
+// conflicts with 409 Conflict. Swap these responses in, so that the errors are
+// displayed, rather than ignoring them like other error responses.
+document.body.addEventListener("htmx:beforeSwap", function (e) {
+	var status = e.detail.xhr.status;
+	if (status === 422 || status === 409) {
+		e.detail.shouldSwap = true;
+		e.detail.isError = false;
+	}
+});
+
+// Display messages sent with the HX-Trigger response header like flash
+// messages.
+document.body.addEventListener("showMessage", function (e) {
+	var main = document.querySelector("main");
+	var flash = main.querySelector(".flash");
+	if (!flash) {
+		flash = document.createElement("div");
+		flash.className = "flash";
+		main.insertBefore(flash, main.firstChild);
+	}
+	flash.textContent = e.detail.value;
+});
+
+// Live updates. On pages showing contacts, listen for contact change events
+// from GET /events, and update the page or show a banner.
+var liveBanner = document.getElementById("live-banner");
+if (liveBanner && window.EventSource) {
+	var contactsTable = document.getElementById("contacts-table");
+	var liveContact = document.getElementById("live-contact");
+	var source = new EventSource("/events");
+
+	var showBanner = function (message) {
+		if (message) {
+			liveBanner.textContent = message + " ";
+			var reload = document.createElement("a");
+			reload.href = window.location.pathname;
+			reload.textContent = "Reload";
+			liveBanner.appendChild(reload);
+		}
+		liveBanner.hidden = false;
+	};
+
+	var findRow = function (id) {
+		return contactsTable && document.getElementById("contact-" + id);
+	};
+
+	// Rows are rendered by the server, by requesting the contact with HTMX.
+	var loadRow = function (id, target, swap) {
+		htmx.ajax("GET", "/contacts/view/" + id, { target: target, swap: swap });
+	};
+
+	source.addEventListener("contact.created", function (e) {
+		var contact = JSON.parse(e.data).contact;
+		if (liveContact) {
+			return;
+		}
+		if (!contactsTable) {
+			showBanner();
+			return;
+		}
+		// Add the row before the row that loads more contacts, if there is one.
+		var loadMore = contactsTable.querySelector(".load-more");
+		if (loadMore) {
+			loadRow(contact.id, loadMore, "beforebegin");
+		} else {
+			loadRow(contact.id, contactsTable, "beforeend");
+		}
+	});
+
+	source.addEventListener("contact.updated", function (e) {
+		var contact = JSON.parse(e.data).contact;
+		if (liveContact) {
+			if (liveContact.dataset.contactId == contact.id &&
+				Number(liveContact.dataset.contactVersion) < contact.version) {
+				showBanner("This contact has been changed by someone else.");
+			}
+			return;
+		}
+		// Rows being edited are left alone. Saving them will report the conflict.
+		var row = findRow(contact.id);
+		if (row && !row.hasAttribute("data-editing") &&
+			Number(row.dataset.contactVersion) < contact.version) {
+			loadRow(contact.id, row, "outerHTML");
+		}
+	});
+
+	source.addEventListener("contact.deleted", function (e) {
+		var contact = JSON.parse(e.data).contact;
+		if (liveContact) {
+			if (liveContact.dataset.contactId == contact.id) {
+				liveBanner.textContent = "This contact has been deleted by someone else.";
+				liveBanner.hidden = false;
+			}
+			return;
+		}
+		var row = findRow(contact.id);
+		if (row) {
+			row.remove();
+		}
+	});
+
+	// Events may have been missed, so the page may be out of date.
+	source.addEventListener("resync", function () {
+		if (liveContact) {
+			showBanner("This contact may have changed.");
+		} else {
+			showBanner();
+		}
+	});
+}
+
+// Select or deselect all contacts for the bulk actions form, including rows
+// loaded later by infinite scroll, which are selected when they appear.
+var selectAll = document.getElementById("select-all");
+if (selectAll) {
+	var setSelected = function (root) {
+		var boxes = root.querySelectorAll('input[name="ids"][form="bulk-form"]');
+		for (var i = 0; i < boxes.length; i++) {
+			boxes[i].checked = selectAll.checked;
+		}
+	};
+	selectAll.addEventListener("change", function () {
+		setSelected(document);
+	});
+	htmx.onLoad(function (elt) {
+		if (selectAll.checked && elt.querySelectorAll) {
+			setSelected(elt);
+		}
+	});
+}
+
+// Send the browser's time zone with forms that have a timezone field, such as
+// the note and reminder forms, so that times are read in it rather than in
+// UTC.
+var timezone = window.Intl && Intl.DateTimeFormat().resolvedOptions().timeZone;
+if (timezone) {
+	var timezoneInputs = document.querySelectorAll('input[name="timezone"]');
+	for (var i = 0; i < timezoneInputs.length; i++) {
+		timezoneInputs[i].value = timezone;
+	}
+	var timezoneLabels = document.querySelectorAll(".timezone-label");
+	for (var i = 0; i < timezoneLabels.length; i++) {
+		timezoneLabels[i].textContent = timezone;
+	}
+}